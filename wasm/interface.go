@@ -0,0 +1,171 @@
+package wasm
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ModuleInterface summarizes the names a module imports and exports, ex. for dependency graphs or documentation.
+// It only contains strings, so it can be serialized, ex. with encoding/json.
+type ModuleInterface struct {
+	Imports []*ImportInterface `json:"imports"`
+	Exports []*ExportInterface `json:"exports"`
+}
+
+// ImportInterface describes an import in the order it is defined in the module.
+type ImportInterface struct {
+	Module string `json:"module"`
+	Name   string `json:"name"`
+	// Kind is one of "func", "table", "memory" or "global".
+	Kind string `json:"kind"`
+	// Type is the signature for the Kind, ex. "[i32 i32]->[i32]" for a func, "mut i64" for a global or "1 2" for a memory
+	// with a min and max of pages. A table is its limits followed by its element type, ex. "1 funcref".
+	Type string `json:"type"`
+}
+
+// ExportInterface describes an export. Exports are sorted by Name, as their order isn't retained by the Module.
+type ExportInterface struct {
+	Name string `json:"name"`
+	// Kind is the same as ImportInterface.Kind
+	Kind string `json:"kind"`
+	// Type is the same as ImportInterface.Type
+	Type string `json:"type"`
+}
+
+// Interface returns the ModuleInterface of the module, or an error if an import or export refers to something the
+// module doesn't define.
+func Interface(m *Module) (*ModuleInterface, error) {
+	ret := &ModuleInterface{Imports: []*ImportInterface{}, Exports: []*ExportInterface{}}
+
+	// Imports come first in each index space, so track them to resolve exports.
+	var funcs []*FunctionType
+	var tables []*TableType
+	var memories []*MemoryType
+	var globals []*GlobalType
+	for i, is := range m.ImportSection {
+		var kind, typ string
+		switch is.Desc.Kind {
+		case ImportKindFunction:
+			idx := *is.Desc.TypeIndexPtr
+			if idx >= uint32(len(m.TypeSection)) {
+				return nil, fmt.Errorf("import[%d] %q %q: unknown type index %d", i, is.Module, is.Name, idx)
+			}
+			funcs = append(funcs, m.TypeSection[idx])
+			kind, typ = "func", m.TypeSection[idx].String()
+		case ImportKindTable:
+			tables = append(tables, is.Desc.TableTypePtr)
+			kind, typ = "table", tableTypeString(is.Desc.TableTypePtr)
+		case ImportKindMemory:
+			memories = append(memories, is.Desc.MemTypePtr)
+			kind, typ = "memory", limitsTypeString(is.Desc.MemTypePtr)
+		case ImportKindGlobal:
+			globals = append(globals, is.Desc.GlobalTypePtr)
+			kind, typ = "global", globalTypeString(is.Desc.GlobalTypePtr)
+		}
+		ret.Imports = append(ret.Imports, &ImportInterface{Module: is.Module, Name: is.Name, Kind: kind, Type: typ})
+	}
+
+	for _, idx := range m.FunctionSection {
+		if idx >= uint32(len(m.TypeSection)) {
+			return nil, fmt.Errorf("function refers to unknown type index %d", idx)
+		}
+		funcs = append(funcs, m.TypeSection[idx])
+	}
+	tables = append(tables, m.TableSection...)
+	memories = append(memories, m.MemorySection...)
+	for _, g := range m.GlobalSection {
+		globals = append(globals, g.Type)
+	}
+
+	names := make([]string, 0, len(m.ExportSection))
+	for name := range m.ExportSection {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		desc := m.ExportSection[name].Desc
+		var kind, typ string
+		var count int
+		switch desc.Kind {
+		case ExportKindFunction:
+			if count = len(funcs); int(desc.Index) < count {
+				kind, typ = "func", funcs[desc.Index].String()
+			}
+		case ExportKindTable:
+			if count = len(tables); int(desc.Index) < count {
+				kind, typ = "table", tableTypeString(tables[desc.Index])
+			}
+		case ExportKindMemory:
+			if count = len(memories); int(desc.Index) < count {
+				kind, typ = "memory", limitsTypeString(memories[desc.Index])
+			}
+		case ExportKindGlobal:
+			if count = len(globals); int(desc.Index) < count {
+				kind, typ = "global", globalTypeString(globals[desc.Index])
+			}
+		}
+		if kind == "" {
+			return nil, fmt.Errorf("export %q: index %d out of range of %d", name, desc.Index, count)
+		}
+		ret.Exports = append(ret.Exports, &ExportInterface{Name: name, Kind: kind, Type: typ})
+	}
+	return ret, nil
+}
+
+func limitsTypeString(l *LimitsType) string {
+	if l.Max == nil {
+		return fmt.Sprintf("%d", l.Min)
+	}
+	return fmt.Sprintf("%d %d", l.Min, *l.Max)
+}
+
+func tableTypeString(t *TableType) string {
+	// funcref is the only element type in WebAssembly 1.0
+	return limitsTypeString(t.Limit) + " funcref"
+}
+
+func globalTypeString(t *GlobalType) string {
+	if t.Mutable {
+		return "mut " + valueTypeName(t.ValType)
+	}
+	return valueTypeName(t.ValType)
+}
+
+// CheckInterface returns an error unless the module exports everything in the required interface, with the same kind
+// and type, and only imports what it allows. The module may export more than required, and needn't import everything
+// allowed. This enforces a contract on a user-supplied module, ex. a plugin.
+func CheckInterface(m *Module, required *ModuleInterface) error {
+	actual, err := Interface(m)
+	if err != nil {
+		return err
+	}
+
+	exports := make(map[string]*ExportInterface, len(actual.Exports))
+	for _, e := range actual.Exports {
+		exports[e.Name] = e
+	}
+	for _, r := range required.Exports {
+		e, ok := exports[r.Name]
+		if !ok {
+			return fmt.Errorf("missing export %q", r.Name)
+		}
+		if e.Kind != r.Kind || e.Type != r.Type {
+			return fmt.Errorf("export %q is %s %s, but %s %s is required", r.Name, e.Kind, e.Type, r.Kind, r.Type)
+		}
+	}
+
+	allowed := make(map[[2]string]*ImportInterface, len(required.Imports))
+	for _, r := range required.Imports {
+		allowed[[2]string{r.Module, r.Name}] = r
+	}
+	for _, i := range actual.Imports {
+		r, ok := allowed[[2]string{i.Module, i.Name}]
+		if !ok {
+			return fmt.Errorf("import %q %q is not allowed", i.Module, i.Name)
+		}
+		if i.Kind != r.Kind || i.Type != r.Type {
+			return fmt.Errorf("import %q %q is %s %s, but only %s %s is allowed", i.Module, i.Name, i.Kind, i.Type, r.Kind, r.Type)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package wasm
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterface(t *testing.T) {
+	typeIndex := uint32(0)
+	max := uint32(2)
+	m := &Module{
+		TypeSection: []*FunctionType{{InputTypes: []ValueType{ValueTypeI32, ValueTypeI32}, ReturnTypes: []ValueType{ValueTypeI32}}},
+		ImportSection: []*ImportSegment{
+			{Module: "env", Name: "add", Desc: &ImportDesc{Kind: ImportKindFunction, TypeIndexPtr: &typeIndex}},
+		},
+		MemorySection: []*MemoryType{{Min: 1, Max: &max}},
+		ExportSection: map[string]*ExportSegment{
+			"memory": {Name: "memory", Desc: &ExportDesc{Kind: ExportKindMemory, Index: 0}},
+		},
+	}
+
+	actual, err := Interface(m)
+	require.NoError(t, err)
+	require.Equal(t, &ModuleInterface{
+		Imports: []*ImportInterface{{Module: "env", Name: "add", Kind: "func", Type: "[i32 i32]->[i32]"}},
+		Exports: []*ExportInterface{{Name: "memory", Kind: "memory", Type: "1 2"}},
+	}, actual)
+
+	// The interface should survive serialization.
+	b, err := json.Marshal(actual)
+	require.NoError(t, err)
+	var decoded ModuleInterface
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	require.Equal(t, actual, &decoded)
+}
+
+func TestInterface_Errors(t *testing.T) {
+	typeIndex := uint32(1)
+	for _, c := range []struct {
+		name   string
+		module *Module
+		expErr string
+	}{
+		{
+			name: "import unknown type",
+			module: &Module{ImportSection: []*ImportSegment{
+				{Module: "env", Name: "add", Desc: &ImportDesc{Kind: ImportKindFunction, TypeIndexPtr: &typeIndex}},
+			}},
+			expErr: `import[0] "env" "add": unknown type index 1`,
+		},
+		{
+			name: "export out of range",
+			module: &Module{ExportSection: map[string]*ExportSegment{
+				"memory": {Name: "memory", Desc: &ExportDesc{Kind: ExportKindMemory, Index: 0}},
+			}},
+			expErr: `export "memory": index 0 out of range of 0`,
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Interface(c.module)
+			require.EqualError(t, err, c.expErr)
+		})
+	}
+}
+
+func TestCheckInterface(t *testing.T) {
+	typeIndex := uint32(0)
+	m := &Module{
+		TypeSection: []*FunctionType{{InputTypes: []ValueType{ValueTypeI32}}},
+		ImportSection: []*ImportSegment{
+			{Module: "env", Name: "log", Desc: &ImportDesc{Kind: ImportKindFunction, TypeIndexPtr: &typeIndex}},
+		},
+		FunctionSection: []uint32{0},
+		CodeSection:     []*CodeSegment{{Body: []byte{OptCodeEnd}}},
+		MemorySection:   []*MemoryType{{Min: 1}},
+		ExportSection: map[string]*ExportSegment{
+			"run":    {Name: "run", Desc: &ExportDesc{Kind: ExportKindFunction, Index: 1}},
+			"memory": {Name: "memory", Desc: &ExportDesc{Kind: ExportKindMemory, Index: 0}},
+		},
+	}
+	log := &ImportInterface{Module: "env", Name: "log", Kind: "func", Type: "[i32]->[]"}
+	run := &ExportInterface{Name: "run", Kind: "func", Type: "[i32]->[]"}
+
+	t.Run("satisfied", func(t *testing.T) {
+		// The memory export isn't required, and the exit import isn't used.
+		exit := &ImportInterface{Module: "env", Name: "exit", Kind: "func", Type: "[i32]->[]"}
+		err := CheckInterface(m, &ModuleInterface{Imports: []*ImportInterface{log, exit}, Exports: []*ExportInterface{run}})
+		require.NoError(t, err)
+	})
+
+	for _, c := range []struct {
+		name     string
+		required *ModuleInterface
+		expErr   string
+	}{
+		{
+			name: "missing export",
+			required: &ModuleInterface{
+				Imports: []*ImportInterface{log},
+				Exports: []*ExportInterface{run, {Name: "init", Kind: "func", Type: "[]->[]"}},
+			},
+			expErr: `missing export "init"`,
+		},
+		{
+			name: "export signature",
+			required: &ModuleInterface{
+				Imports: []*ImportInterface{log},
+				Exports: []*ExportInterface{{Name: "run", Kind: "func", Type: "[]->[i32]"}},
+			},
+			expErr: `export "run" is func [i32]->[], but func []->[i32] is required`,
+		},
+		{
+			name:     "import not allowed",
+			required: &ModuleInterface{Exports: []*ExportInterface{run}},
+			expErr:   `import "env" "log" is not allowed`,
+		},
+		{
+			name: "import signature",
+			required: &ModuleInterface{
+				Imports: []*ImportInterface{{Module: "env", Name: "log", Kind: "func", Type: "[i64]->[]"}},
+			},
+			expErr: `import "env" "log" is func [i32]->[], but only func [i64]->[] is allowed`,
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			require.EqualError(t, CheckInterface(m, c.required), c.expErr)
+		})
+	}
+}
@@ -3,12 +3,112 @@ package leb128
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+func TestEncodeDecodeInt32(t *testing.T) {
+	for _, c := range []struct {
+		input    int32
+		expected []byte
+	}{
+		{input: -165675008, expected: []byte{0x80, 0x80, 0x80, 0xb1, 0x7f}},
+		{input: -624485, expected: []byte{0x9b, 0xf1, 0x59}},
+		{input: -16256, expected: []byte{0x80, 0x81, 0x7f}},
+		{input: -4, expected: []byte{0x7c}},
+		{input: -1, expected: []byte{0x7f}},
+		{input: 0, expected: []byte{0x00}},
+		{input: 1, expected: []byte{0x01}},
+		{input: 4, expected: []byte{0x04}},
+		{input: 16256, expected: []byte{0x80, 0xff, 0x0}},
+		{input: 624485, expected: []byte{0xe5, 0x8e, 0x26}},
+		{input: 165675008, expected: []byte{0x80, 0x80, 0x80, 0xcf, 0x0}},
+		{input: math.MaxInt32, expected: []byte{0xff, 0xff, 0xff, 0xff, 0x7}},
+		{input: math.MinInt32, expected: []byte{0x80, 0x80, 0x80, 0x80, 0x78}},
+	} {
+		require.Equal(t, c.expected, EncodeInt32(c.input))
+		decoded, num, err := DecodeInt32(bytes.NewReader(c.expected))
+		require.NoError(t, err)
+		require.Equal(t, c.input, decoded)
+		require.Equal(t, uint64(len(c.expected)), num)
+	}
+}
+
+func TestEncodeDecodeInt64(t *testing.T) {
+	for _, c := range []struct {
+		input    int64
+		expected []byte
+	}{
+		{input: -math.MaxInt32, expected: []byte{0x81, 0x80, 0x80, 0x80, 0x78}},
+		{input: -165675008, expected: []byte{0x80, 0x80, 0x80, 0xb1, 0x7f}},
+		{input: -624485, expected: []byte{0x9b, 0xf1, 0x59}},
+		{input: -16256, expected: []byte{0x80, 0x81, 0x7f}},
+		{input: -4, expected: []byte{0x7c}},
+		{input: -1, expected: []byte{0x7f}},
+		{input: 0, expected: []byte{0x00}},
+		{input: 1, expected: []byte{0x01}},
+		{input: 4, expected: []byte{0x04}},
+		{input: 16256, expected: []byte{0x80, 0xff, 0x0}},
+		{input: 624485, expected: []byte{0xe5, 0x8e, 0x26}},
+		{input: 165675008, expected: []byte{0x80, 0x80, 0x80, 0xcf, 0x0}},
+		{input: math.MaxInt32, expected: []byte{0xff, 0xff, 0xff, 0xff, 0x7}},
+		{input: math.MaxInt64, expected: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x0}},
+		{input: math.MinInt64, expected: []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x7f}},
+	} {
+		require.Equal(t, c.expected, EncodeInt64(c.input))
+		decoded, num, err := DecodeInt64(bytes.NewReader(c.expected))
+		require.NoError(t, err)
+		require.Equal(t, c.input, decoded)
+		require.Equal(t, uint64(len(c.expected)), num)
+	}
+}
+
+func TestEncodeDecodeUint32(t *testing.T) {
+	for _, c := range []struct {
+		input    uint32
+		expected []byte
+	}{
+		{input: 0, expected: []byte{0x00}},
+		{input: 1, expected: []byte{0x01}},
+		{input: 4, expected: []byte{0x04}},
+		{input: 16256, expected: []byte{0x80, 0x7f}},
+		{input: 624485, expected: []byte{0xe5, 0x8e, 0x26}},
+		{input: 165675008, expected: []byte{0x80, 0x80, 0x80, 0x4f}},
+		{input: math.MaxUint32, expected: []byte{0xff, 0xff, 0xff, 0xff, 0xf}},
+	} {
+		require.Equal(t, c.expected, EncodeUint32(c.input))
+		decoded, num, err := DecodeUint32(bytes.NewReader(c.expected))
+		require.NoError(t, err)
+		require.Equal(t, c.input, decoded)
+		require.Equal(t, uint64(len(c.expected)), num)
+	}
+}
+
+func TestEncodeDecodeUint64(t *testing.T) {
+	for _, c := range []struct {
+		input    uint64
+		expected []byte
+	}{
+		{input: 0, expected: []byte{0x00}},
+		{input: 1, expected: []byte{0x01}},
+		{input: 4, expected: []byte{0x04}},
+		{input: 16256, expected: []byte{0x80, 0x7f}},
+		{input: 624485, expected: []byte{0xe5, 0x8e, 0x26}},
+		{input: 165675008, expected: []byte{0x80, 0x80, 0x80, 0x4f}},
+		{input: math.MaxUint32, expected: []byte{0xff, 0xff, 0xff, 0xff, 0xf}},
+		{input: math.MaxUint64, expected: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x1}},
+	} {
+		require.Equal(t, c.expected, EncodeUint64(c.input))
+		decoded, num, err := DecodeUint64(bytes.NewReader(c.expected))
+		require.NoError(t, err)
+		require.Equal(t, c.input, decoded)
+		require.Equal(t, uint64(len(c.expected)), num)
+	}
+}
+
 func TestDecodeUint32(t *testing.T) {
 	for _, c := range []struct {
 		bytes  []byte
@@ -0,0 +1,67 @@
+package wasm
+
+import "fmt"
+
+// ModuleMetrics estimate the cost of executing a module before running it, ex. for scheduling or quotas on untrusted
+// modules.
+type ModuleMetrics struct {
+	// Functions are the metrics of each function defined by the module, in the order of the code section. Imported
+	// functions aren't included.
+	Functions []*FunctionMetrics `json:"functions"`
+	// Instructions is the sum of the Instructions of all Functions.
+	Instructions uint64 `json:"instructions"`
+	// Locals is the sum of the Locals of all Functions.
+	Locals uint64 `json:"locals"`
+}
+
+// FunctionMetrics are the metrics of a function body.
+type FunctionMetrics struct {
+	// Instructions is the count of instructions in the body, including each "end".
+	Instructions uint64 `json:"instructions"`
+	// MaxDepth is the deepest nesting of block, loop and if instructions, or zero if the body has none.
+	MaxDepth uint64 `json:"max_depth"`
+	// Locals is the count of the function's params and declared locals.
+	Locals uint64 `json:"locals"`
+}
+
+// Metrics returns the ModuleMetrics of the module, or an error if a function refers to an unknown type or a body is
+// malformed. The module isn't otherwise validated.
+func Metrics(m *Module) (*ModuleMetrics, error) {
+	if len(m.FunctionSection) != len(m.CodeSection) {
+		return nil, fmt.Errorf("function and code section have inconsistent lengths: %d != %d",
+			len(m.FunctionSection), len(m.CodeSection))
+	}
+
+	ret := &ModuleMetrics{Functions: make([]*FunctionMetrics, 0, len(m.CodeSection))}
+	for i, c := range m.CodeSection {
+		typeIndex := m.FunctionSection[i]
+		if typeIndex >= uint32(len(m.TypeSection)) {
+			return nil, fmt.Errorf("function %d: unknown type index %d", i, typeIndex)
+		}
+		f := &FunctionMetrics{Locals: uint64(len(m.TypeSection[typeIndex].InputTypes)) + uint64(c.NumLocals)}
+
+		var depth uint64
+		err := walkInstructions(c.Body, func(pc, next int) error {
+			f.Instructions++
+			switch c.Body[pc] {
+			case OptCodeBlock, OptCodeLoop, OptCodeIf:
+				if depth++; depth > f.MaxDepth {
+					f.MaxDepth = depth
+				}
+			case OptCodeEnd:
+				if depth > 0 { // otherwise, this ends the function
+					depth--
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("code %d: %w", i, err)
+		}
+
+		ret.Functions = append(ret.Functions, f)
+		ret.Instructions += f.Instructions
+		ret.Locals += f.Locals
+	}
+	return ret, nil
+}
@@ -0,0 +1,100 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_Fibonacci(t *testing.T) {
+	// This is jit/testdata/fib.wasm, which recursively computes a Fibonacci number.
+	mod, err := DecodeModule([]byte{
+		0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+		0x01, 0x06, 0x01, 0x60, 0x01, 0x7e, 0x01, 0x7e,
+		0x03, 0x02, 0x01, 0x00,
+		0x07, 0x07, 0x01, 0x03, 'f', 'i', 'b', 0x00, 0x00,
+		0x0a, 0x1e, 0x01, 0x1c, 0x00,
+		0x20, 0x00, 0x42, 0x01, 0x58, 0x04, 0x7e, // (if (result i64) (i64.le_u (local.get 0) (i64.const 1))
+		0x42, 0x01, 0x05, // (then (i64.const 1)) (else
+		0x20, 0x00, 0x42, 0x02, 0x7d, 0x10, 0x00, // (call $fib (i64.sub (local.get 0) (i64.const 2)))
+		0x20, 0x00, 0x42, 0x01, 0x7d, 0x10, 0x00, // (call $fib (i64.sub (local.get 0) (i64.const 1)))
+		0x7c, 0x0b, 0x0b, // i64.add, then the end of the if and the function
+	})
+	require.NoError(t, err)
+
+	actual, err := Metrics(mod)
+	require.NoError(t, err)
+	// Counted by hand: 3 before the if, the if, 1 in then, the else, 9 in else, and 2 ends.
+	require.Equal(t, &ModuleMetrics{
+		Functions:    []*FunctionMetrics{{Instructions: 17, MaxDepth: 1, Locals: 1}},
+		Instructions: 17,
+		Locals:       1,
+	}, actual)
+}
+
+func TestMetrics(t *testing.T) {
+	m := &Module{
+		TypeSection:     []*FunctionType{{}, {InputTypes: []ValueType{ValueTypeI32, ValueTypeI32}}},
+		FunctionSection: []uint32{0, 1},
+		CodeSection: []*CodeSegment{
+			{Body: []byte{OptCodeEnd}},
+			{
+				NumLocals:  3,
+				LocalTypes: []ValueType{ValueTypeI64, ValueTypeI64, ValueTypeI64},
+				// (block (loop (block))) (block)
+				Body: []byte{
+					OptCodeBlock, 0x40, OptCodeLoop, 0x40, OptCodeBlock, 0x40, OptCodeEnd, OptCodeEnd, OptCodeEnd,
+					OptCodeBlock, 0x40, OptCodeEnd, OptCodeEnd,
+				},
+			},
+		},
+	}
+
+	actual, err := Metrics(m)
+	require.NoError(t, err)
+	require.Equal(t, &ModuleMetrics{
+		Functions: []*FunctionMetrics{
+			{Instructions: 1},
+			{Instructions: 9, MaxDepth: 3, Locals: 5},
+		},
+		Instructions: 10,
+		Locals:       5,
+	}, actual)
+}
+
+func TestMetrics_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       *Module
+		expectedErr string
+	}{
+		{
+			name:        "inconsistent lengths",
+			input:       &Module{FunctionSection: []uint32{0}},
+			expectedErr: "function and code section have inconsistent lengths: 1 != 0",
+		},
+		{
+			name:        "unknown type",
+			input:       &Module{FunctionSection: []uint32{1}, CodeSection: []*CodeSegment{{Body: []byte{OptCodeEnd}}}},
+			expectedErr: "function 0: unknown type index 1",
+		},
+		{
+			name: "truncated immediate",
+			input: &Module{
+				TypeSection:     []*FunctionType{{}},
+				FunctionSection: []uint32{0},
+				CodeSection:     []*CodeSegment{{Body: []byte{OptCodeF32Const, 0x00}}},
+			},
+			expectedErr: "code 0: read immediate at 0: unexpected EOF",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Metrics(tc.input)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
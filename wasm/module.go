@@ -73,7 +73,8 @@ func DecodeModule(binary []byte) (*Module, error) {
 	}
 
 	if len(ret.FunctionSection) != len(ret.CodeSection) {
-		return nil, fmt.Errorf("function and code section have inconsistent lengths")
+		return nil, fmt.Errorf("function section has %d entries but code section has %d",
+			len(ret.FunctionSection), len(ret.CodeSection))
 	}
 	return ret, nil
 }
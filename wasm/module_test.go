@@ -28,6 +28,62 @@ func TestDecodeModule(t *testing.T) {
 			input:       []byte("\x00asm\x01\x00\x00\x01"),
 			expectedErr: "invalid version header",
 		},
+		{
+			name: "duplicate section",
+			input: []byte("\x00asm\x01\x00\x00\x00" +
+				"\x03\x01\x00" + // empty function section
+				"\x03\x01\x00"),
+			expectedErr: "readSections failed: byte offset 11: duplicate section id 3",
+		},
+		{
+			name: "section out of order",
+			input: []byte("\x00asm\x01\x00\x00\x00" +
+				"\x03\x01\x00" + // empty function section
+				"\x01\x01\x00"), // empty type section
+			expectedErr: "readSections failed: byte offset 11: section id 1 out of order",
+		},
+		{
+			name: "custom sections anywhere",
+			input: []byte("\x00asm\x01\x00\x00\x00" +
+				"\x00\x02\x01a" + // custom section "a"
+				"\x01\x01\x00" + // empty type section
+				"\x00\x02\x01b" + // custom section "b"
+				"\x03\x01\x00"), // empty function section
+			expected: &Module{
+				TypeSection:     []*FunctionType{},
+				FunctionSection: []uint32{},
+				CustomSections:  map[string][]byte{"a": {}, "b": {}},
+			},
+		},
+		{
+			name: "function without code",
+			input: []byte("\x00asm\x01\x00\x00\x00" +
+				"\x01\x04\x01\x60\x00\x00" + // type section: () -> ()
+				"\x03\x02\x01\x00"), // function section: one function of type 0
+			expectedErr: "function section has 1 entries but code section has 0",
+		},
+		{
+			name: "function body missing end",
+			input: []byte("\x00asm\x01\x00\x00\x00" +
+				"\x01\x04\x01\x60\x00\x00" + // type section: () -> ()
+				"\x03\x02\x01\x00" + // function section: one function of type 0
+				"\x0a\x04\x01\x02\x00\x01"), // code section: one body with no locals and only a nop
+			expectedErr: "readSections failed: section ID 10: read 0-th code segment: function body missing end",
+		},
+		{
+			name: "empty function body",
+			input: []byte("\x00asm\x01\x00\x00\x00" +
+				"\x01\x04\x01\x60\x00\x00" + // type section: () -> ()
+				"\x03\x02\x01\x00" + // function section: one function of type 0
+				"\x0a\x03\x01\x01\x00"), // code section: one body with no locals and no instructions
+			expectedErr: "readSections failed: section ID 10: read 0-th code segment: function body missing end",
+		},
+		{
+			name: "code without function",
+			input: []byte("\x00asm\x01\x00\x00\x00" +
+				"\x0a\x04\x01\x02\x00\x0b"), // code section: one empty body
+			expectedErr: "function section has 0 entries but code section has 1",
+		},
 	}
 
 	for _, tt := range tests {
@@ -216,3 +216,189 @@ const (
 	OptCodeF32Reinterpreti32 OptCode = 0xbe
 	OptCodeF64Reinterpreti64 OptCode = 0xbf
 )
+
+// optCodeNames are the canonical text format mnemonics of each OptCode, indexed by OptCode.
+// See https://www.w3.org/TR/wasm-core-1/#instructions%E2%91%A6
+var optCodeNames = [...]string{
+	OptCodeUnreachable:       "unreachable",
+	OptCodeNop:               "nop",
+	OptCodeBlock:             "block",
+	OptCodeLoop:              "loop",
+	OptCodeIf:                "if",
+	OptCodeElse:              "else",
+	OptCodeEnd:               "end",
+	OptCodeBr:                "br",
+	OptCodeBrIf:              "br_if",
+	OptCodeBrTable:           "br_table",
+	OptCodeReturn:            "return",
+	OptCodeCall:              "call",
+	OptCodeCallIndirect:      "call_indirect",
+	OptCodeDrop:              "drop",
+	OptCodeSelect:            "select",
+	OptCodeLocalGet:          "local.get",
+	OptCodeLocalSet:          "local.set",
+	OptCodeLocalTee:          "local.tee",
+	OptCodeGlobalGet:         "global.get",
+	OptCodeGlobalSet:         "global.set",
+	OptCodeI32Load:           "i32.load",
+	OptCodeI64Load:           "i64.load",
+	OptCodeF32Load:           "f32.load",
+	OptCodeF64Load:           "f64.load",
+	OptCodeI32Load8s:         "i32.load8_s",
+	OptCodeI32Load8u:         "i32.load8_u",
+	OptCodeI32Load16s:        "i32.load16_s",
+	OptCodeI32Load16u:        "i32.load16_u",
+	OptCodeI64Load8s:         "i64.load8_s",
+	OptCodeI64Load8u:         "i64.load8_u",
+	OptCodeI64Load16s:        "i64.load16_s",
+	OptCodeI64Load16u:        "i64.load16_u",
+	OptCodeI64Load32s:        "i64.load32_s",
+	OptCodeI64Load32u:        "i64.load32_u",
+	OptCodeI32Store:          "i32.store",
+	OptCodeI64Store:          "i64.store",
+	OptCodeF32Store:          "f32.store",
+	OptCodeF64Store:          "f64.store",
+	OptCodeI32Store8:         "i32.store8",
+	OptCodeI32Store16:        "i32.store16",
+	OptCodeI64Store8:         "i64.store8",
+	OptCodeI64Store16:        "i64.store16",
+	OptCodeI64Store32:        "i64.store32",
+	OptCodeMemorySize:        "memory.size",
+	OptCodeMemoryGrow:        "memory.grow",
+	OptCodeI32Const:          "i32.const",
+	OptCodeI64Const:          "i64.const",
+	OptCodeF32Const:          "f32.const",
+	OptCodeF64Const:          "f64.const",
+	OptCodeI32eqz:            "i32.eqz",
+	OptCodeI32eq:             "i32.eq",
+	OptCodeI32ne:             "i32.ne",
+	OptCodeI32lts:            "i32.lt_s",
+	OptCodeI32ltu:            "i32.lt_u",
+	OptCodeI32gts:            "i32.gt_s",
+	OptCodeI32gtu:            "i32.gt_u",
+	OptCodeI32les:            "i32.le_s",
+	OptCodeI32leu:            "i32.le_u",
+	OptCodeI32ges:            "i32.ge_s",
+	OptCodeI32geu:            "i32.ge_u",
+	OptCodeI64eqz:            "i64.eqz",
+	OptCodeI64eq:             "i64.eq",
+	OptCodeI64ne:             "i64.ne",
+	OptCodeI64lts:            "i64.lt_s",
+	OptCodeI64ltu:            "i64.lt_u",
+	OptCodeI64gts:            "i64.gt_s",
+	OptCodeI64gtu:            "i64.gt_u",
+	OptCodeI64les:            "i64.le_s",
+	OptCodeI64leu:            "i64.le_u",
+	OptCodeI64ges:            "i64.ge_s",
+	OptCodeI64geu:            "i64.ge_u",
+	OptCodeF32eq:             "f32.eq",
+	OptCodeF32ne:             "f32.ne",
+	OptCodeF32lt:             "f32.lt",
+	OptCodeF32gt:             "f32.gt",
+	OptCodeF32le:             "f32.le",
+	OptCodeF32ge:             "f32.ge",
+	OptCodeF64eq:             "f64.eq",
+	OptCodeF64ne:             "f64.ne",
+	OptCodeF64lt:             "f64.lt",
+	OptCodeF64gt:             "f64.gt",
+	OptCodeF64le:             "f64.le",
+	OptCodeF64ge:             "f64.ge",
+	OptCodeI32clz:            "i32.clz",
+	OptCodeI32ctz:            "i32.ctz",
+	OptCodeI32popcnt:         "i32.popcnt",
+	OptCodeI32add:            "i32.add",
+	OptCodeI32sub:            "i32.sub",
+	OptCodeI32mul:            "i32.mul",
+	OptCodeI32divs:           "i32.div_s",
+	OptCodeI32divu:           "i32.div_u",
+	OptCodeI32rems:           "i32.rem_s",
+	OptCodeI32remu:           "i32.rem_u",
+	OptCodeI32and:            "i32.and",
+	OptCodeI32or:             "i32.or",
+	OptCodeI32xor:            "i32.xor",
+	OptCodeI32shl:            "i32.shl",
+	OptCodeI32shrs:           "i32.shr_s",
+	OptCodeI32shru:           "i32.shr_u",
+	OptCodeI32rotl:           "i32.rotl",
+	OptCodeI32rotr:           "i32.rotr",
+	OptCodeI64clz:            "i64.clz",
+	OptCodeI64ctz:            "i64.ctz",
+	OptCodeI64popcnt:         "i64.popcnt",
+	OptCodeI64add:            "i64.add",
+	OptCodeI64sub:            "i64.sub",
+	OptCodeI64mul:            "i64.mul",
+	OptCodeI64divs:           "i64.div_s",
+	OptCodeI64divu:           "i64.div_u",
+	OptCodeI64rems:           "i64.rem_s",
+	OptCodeI64remu:           "i64.rem_u",
+	OptCodeI64and:            "i64.and",
+	OptCodeI64or:             "i64.or",
+	OptCodeI64xor:            "i64.xor",
+	OptCodeI64shl:            "i64.shl",
+	OptCodeI64shrs:           "i64.shr_s",
+	OptCodeI64shru:           "i64.shr_u",
+	OptCodeI64rotl:           "i64.rotl",
+	OptCodeI64rotr:           "i64.rotr",
+	OptCodeF32abs:            "f32.abs",
+	OptCodeF32neg:            "f32.neg",
+	OptCodeF32ceil:           "f32.ceil",
+	OptCodeF32floor:          "f32.floor",
+	OptCodeF32trunc:          "f32.trunc",
+	OptCodeF32nearest:        "f32.nearest",
+	OptCodeF32sqrt:           "f32.sqrt",
+	OptCodeF32add:            "f32.add",
+	OptCodeF32sub:            "f32.sub",
+	OptCodeF32mul:            "f32.mul",
+	OptCodeF32div:            "f32.div",
+	OptCodeF32min:            "f32.min",
+	OptCodeF32max:            "f32.max",
+	OptCodeF32copysign:       "f32.copysign",
+	OptCodeF64abs:            "f64.abs",
+	OptCodeF64neg:            "f64.neg",
+	OptCodeF64ceil:           "f64.ceil",
+	OptCodeF64floor:          "f64.floor",
+	OptCodeF64trunc:          "f64.trunc",
+	OptCodeF64nearest:        "f64.nearest",
+	OptCodeF64sqrt:           "f64.sqrt",
+	OptCodeF64add:            "f64.add",
+	OptCodeF64sub:            "f64.sub",
+	OptCodeF64mul:            "f64.mul",
+	OptCodeF64div:            "f64.div",
+	OptCodeF64min:            "f64.min",
+	OptCodeF64max:            "f64.max",
+	OptCodeF64copysign:       "f64.copysign",
+	OptCodeI32wrapI64:        "i32.wrap_i64",
+	OptCodeI32truncf32s:      "i32.trunc_f32_s",
+	OptCodeI32truncf32u:      "i32.trunc_f32_u",
+	OptCodeI32truncf64s:      "i32.trunc_f64_s",
+	OptCodeI32truncf64u:      "i32.trunc_f64_u",
+	OptCodeI64Extendi32s:     "i64.extend_i32_s",
+	OptCodeI64Extendi32u:     "i64.extend_i32_u",
+	OptCodeI64TruncF32s:      "i64.trunc_f32_s",
+	OptCodeI64TruncF32u:      "i64.trunc_f32_u",
+	OptCodeI64Truncf64s:      "i64.trunc_f64_s",
+	OptCodeI64Truncf64u:      "i64.trunc_f64_u",
+	OptCodeF32Converti32s:    "f32.convert_i32_s",
+	OptCodeF32Converti32u:    "f32.convert_i32_u",
+	OptCodeF32Converti64s:    "f32.convert_i64_s",
+	OptCodeF32Converti64u:    "f32.convert_i64_u",
+	OptCodeF32Demotef64:      "f32.demote_f64",
+	OptCodeF64Converti32s:    "f64.convert_i32_s",
+	OptCodeF64Converti32u:    "f64.convert_i32_u",
+	OptCodeF64Converti64s:    "f64.convert_i64_s",
+	OptCodeF64Converti64u:    "f64.convert_i64_u",
+	OptCodeF64Promotef32:     "f64.promote_f32",
+	OptCodeI32Reinterpretf32: "i32.reinterpret_f32",
+	OptCodeI64Reinterpretf64: "i64.reinterpret_f64",
+	OptCodeF32Reinterpreti32: "f32.reinterpret_i32",
+	OptCodeF64Reinterpreti64: "f64.reinterpret_i64",
+}
+
+// OptCodeName returns the canonical text format mnemonic of the given OptCode, e.g. "i32.add" for OptCodeI32add.
+// Returns false if the byte is not assigned to an instruction.
+func OptCodeName(op OptCode) (string, bool) {
+	if int(op) >= len(optCodeNames) || optCodeNames[op] == "" {
+		return "", false
+	}
+	return optCodeNames[op], true
+}
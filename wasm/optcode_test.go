@@ -0,0 +1,31 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptCodeName(t *testing.T) {
+	for _, c := range []struct {
+		op       OptCode
+		expected string
+	}{
+		{op: OptCodeUnreachable, expected: "unreachable"},
+		{op: OptCodeBrIf, expected: "br_if"},
+		{op: OptCodeLocalGet, expected: "local.get"},
+		{op: OptCodeI64Load32u, expected: "i64.load32_u"},
+		{op: OptCodeMemoryGrow, expected: "memory.grow"},
+		{op: 0x6a, expected: "i32.add"},
+		{op: OptCodeF64Reinterpreti64, expected: "f64.reinterpret_i64"},
+	} {
+		actual, ok := OptCodeName(c.op)
+		require.True(t, ok)
+		require.Equal(t, c.expected, actual)
+	}
+
+	for _, op := range []OptCode{0x06, 0x1c, 0x27, 0xc0, 0xfc, 0xff} {
+		_, ok := OptCodeName(op)
+		require.False(t, ok, "%#x", op)
+	}
+}
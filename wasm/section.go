@@ -26,7 +26,11 @@ const (
 )
 
 func (m *Module) readSections(r *reader) error {
+	// Known sections must appear at most once and in the order of their IDs. Custom sections may appear anywhere.
+	// See https://www.w3.org/TR/wasm-core-1/#modules%E2%91%A0%E2%93%AA
+	lastSectionID := SectionIDCustom
 	for {
+		sectionOffset := r.read
 		b := make([]byte, 1)
 		if _, err := io.ReadFull(r, b); err == io.EOF {
 			return nil
@@ -34,6 +38,15 @@ func (m *Module) readSections(r *reader) error {
 			return fmt.Errorf("read section id: %w", err)
 		}
 
+		if id := b[0]; id != SectionIDCustom {
+			if id == lastSectionID {
+				return fmt.Errorf("byte offset %d: duplicate section id %d", sectionOffset, id)
+			} else if id < lastSectionID {
+				return fmt.Errorf("byte offset %d: section id %d out of order", sectionOffset, id)
+			}
+			lastSectionID = id
+		}
+
 		ss, _, err := leb128.DecodeUint32(r)
 		if err != nil {
 			return fmt.Errorf("get size of section for id=%d: %v", SectionID(b[0]), err)
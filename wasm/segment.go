@@ -1,9 +1,11 @@
 package wasm
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"math"
+	"sort"
 
 	"github.com/tetratelabs/wazero/wasm/leb128"
 )
@@ -275,8 +277,8 @@ func readCodeSegment(r io.Reader) (*CodeSegment, error) {
 		return nil, fmt.Errorf("read body: %w", err)
 	}
 
-	if body[len(body)-1] != byte(OptCodeEnd) {
-		return nil, fmt.Errorf("expr not end with OptCodeEnd")
+	if len(body) == 0 || body[len(body)-1] != OptCodeEnd {
+		return nil, fmt.Errorf("function body missing end")
 	}
 
 	return &CodeSegment{
@@ -322,3 +324,129 @@ func readDataSegment(r io.Reader) (*DataSegment, error) {
 		Init:             b,
 	}, nil
 }
+
+// MergeDataSegments returns a copy of the module whose data section has adjacent or overlapping segments merged.
+//
+// Only consecutive segments with constant (i32.const) offsets are merged, so the relative order of segments whose
+// offsets depend on imported globals is kept. It is an error for overlapping segments to disagree on a byte.
+func (m *Module) MergeDataSegments() (*Module, error) {
+	ret := *m
+	ret.DataSection = nil
+
+	var run []*dataSegmentRange
+	flush := func() {
+		ret.DataSection = append(ret.DataSection, mergeDataSegmentRanges(run)...)
+		run = nil
+	}
+	for i, d := range m.DataSection {
+		r, ok, err := newDataSegmentRange(d)
+		if err != nil {
+			return nil, fmt.Errorf("data segment %d: %w", i, err)
+		} else if !ok {
+			flush()
+			ret.DataSection = append(ret.DataSection, d)
+			continue
+		}
+		for _, prev := range run {
+			if err := prev.checkConflict(r); err != nil {
+				return nil, fmt.Errorf("data segment %d: %w", i, err)
+			}
+		}
+		run = append(run, r)
+	}
+	flush()
+	return &ret, nil
+}
+
+// MinMemoryPages returns the minimum number of pages a memory needs so that every segment fits, ex. 2 when a segment
+// ends at byte 70000.
+//
+// This returns an error if a segment's offset isn't a non-negative constant (i32.const), as its end isn't known until
+// instantiation.
+func MinMemoryPages(segments []*DataSegment) (uint32, error) {
+	var end uint64
+	for i, d := range segments {
+		r, ok, err := newDataSegmentRange(d)
+		if err != nil {
+			return 0, fmt.Errorf("data segment %d: %w", i, err)
+		} else if !ok {
+			return 0, fmt.Errorf("data segment %d: offset must be a non-negative constant", i)
+		}
+		if r.end() > end {
+			end = r.end()
+		}
+	}
+	return uint32((end + PageSize - 1) / PageSize), nil
+}
+
+// dataSegmentRange is a DataSegment with a constant offset.
+type dataSegmentRange struct {
+	offset uint64
+	init   []byte
+}
+
+func newDataSegmentRange(d *DataSegment) (*dataSegmentRange, bool, error) {
+	if d.OffsetExpression.OptCode != OptCodeI32Const {
+		return nil, false, nil
+	}
+	offset, _, err := leb128.DecodeInt32(bytes.NewReader(d.OffsetExpression.Data))
+	if err != nil {
+		return nil, false, fmt.Errorf("read offset: %w", err)
+	} else if offset < 0 {
+		// Negative offsets fail at instantiation, so leave the segment as is.
+		return nil, false, nil
+	}
+	return &dataSegmentRange{offset: uint64(offset), init: d.Init}, true, nil
+}
+
+func (r *dataSegmentRange) end() uint64 {
+	return r.offset + uint64(len(r.init))
+}
+
+func (r *dataSegmentRange) checkConflict(o *dataSegmentRange) error {
+	begin, end := r.offset, r.end()
+	if o.offset > begin {
+		begin = o.offset
+	}
+	if o.end() < end {
+		end = o.end()
+	}
+	for p := begin; p < end; p++ {
+		if r.init[p-r.offset] != o.init[p-o.offset] {
+			return fmt.Errorf("conflicting overlap with another segment at memory offset %d", p)
+		}
+	}
+	return nil
+}
+
+func mergeDataSegmentRanges(ranges []*dataSegmentRange) (ret []*DataSegment) {
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].offset < ranges[j].offset })
+
+	var cur *dataSegmentRange
+	emit := func() {
+		if cur != nil {
+			ret = append(ret, &DataSegment{
+				OffsetExpression: &ConstantExpression{
+					OptCode: OptCodeI32Const,
+					Data:    leb128.EncodeInt32(int32(cur.offset)),
+				},
+				Init: cur.init,
+			})
+		}
+	}
+	for _, r := range ranges {
+		if cur != nil && r.offset <= cur.end() {
+			if r.end() > cur.end() {
+				init := make([]byte, r.end()-cur.offset)
+				copy(init, cur.init)
+				copy(init[r.offset-cur.offset:], r.init)
+				cur = &dataSegmentRange{offset: cur.offset, init: init}
+			}
+			continue
+		}
+		emit()
+		cur = r
+	}
+	emit()
+	return
+}
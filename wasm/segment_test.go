@@ -0,0 +1,103 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/wazero/wasm/leb128"
+)
+
+func dataAtOffset(offset int32, init string) *DataSegment {
+	return &DataSegment{
+		OffsetExpression: &ConstantExpression{OptCode: OptCodeI32Const, Data: leb128.EncodeInt32(offset)},
+		Init:             []byte(init),
+	}
+}
+
+var globalGetData = &DataSegment{
+	OffsetExpression: &ConstantExpression{OptCode: OptCodeGlobalGet, Data: leb128.EncodeUint32(0)},
+	Init:             []byte("g"),
+}
+
+func TestModule_MergeDataSegments(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		input    []*DataSegment
+		expected []*DataSegment
+	}{
+		{
+			name:     "contiguous",
+			input:    []*DataSegment{dataAtOffset(0, "ab"), dataAtOffset(2, "cd")},
+			expected: []*DataSegment{dataAtOffset(0, "abcd")},
+		},
+		{
+			name:     "contiguous out of order",
+			input:    []*DataSegment{dataAtOffset(2, "cd"), dataAtOffset(0, "ab")},
+			expected: []*DataSegment{dataAtOffset(0, "abcd")},
+		},
+		{
+			name:     "overlapping",
+			input:    []*DataSegment{dataAtOffset(0, "abc"), dataAtOffset(1, "bcd"), dataAtOffset(1, "b")},
+			expected: []*DataSegment{dataAtOffset(0, "abcd")},
+		},
+		{
+			name:     "non-adjacent",
+			input:    []*DataSegment{dataAtOffset(0, "ab"), dataAtOffset(3, "cd")},
+			expected: []*DataSegment{dataAtOffset(0, "ab"), dataAtOffset(3, "cd")},
+		},
+		{
+			name:     "non-constant offset breaks runs",
+			input:    []*DataSegment{dataAtOffset(0, "ab"), globalGetData, dataAtOffset(2, "cd")},
+			expected: []*DataSegment{dataAtOffset(0, "ab"), globalGetData, dataAtOffset(2, "cd")},
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			m := &Module{DataSection: c.input}
+			merged, err := m.MergeDataSegments()
+			require.NoError(t, err)
+			require.Equal(t, c.expected, merged.DataSection)
+			// The original module must not be modified.
+			require.Equal(t, c.input, m.DataSection)
+		})
+	}
+
+	t.Run("conflict", func(t *testing.T) {
+		m := &Module{DataSection: []*DataSegment{dataAtOffset(0, "abc"), dataAtOffset(1, "bx")}}
+		_, err := m.MergeDataSegments()
+		require.EqualError(t, err, "data segment 1: conflicting overlap with another segment at memory offset 2")
+	})
+}
+
+func TestMinMemoryPages(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		input    []*DataSegment
+		expected uint32
+	}{
+		{name: "none"},
+		{name: "empty segment", input: []*DataSegment{dataAtOffset(0, "")}},
+		{name: "one byte", input: []*DataSegment{dataAtOffset(0, "a")}, expected: 1},
+		{name: "fills a page", input: []*DataSegment{dataAtOffset(65535, "a")}, expected: 1},
+		{name: "ends at byte 70000", input: []*DataSegment{dataAtOffset(69999, "a")}, expected: 2},
+		{name: "largest end wins", input: []*DataSegment{dataAtOffset(69999, "a"), dataAtOffset(0, "a")}, expected: 2},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			pages, err := MinMemoryPages(c.input)
+			require.NoError(t, err)
+			require.Equal(t, c.expected, pages)
+		})
+	}
+
+	t.Run("non-constant offset", func(t *testing.T) {
+		_, err := MinMemoryPages([]*DataSegment{dataAtOffset(0, "a"), globalGetData})
+		require.EqualError(t, err, "data segment 1: offset must be a non-negative constant")
+	})
+
+	t.Run("negative offset", func(t *testing.T) {
+		_, err := MinMemoryPages([]*DataSegment{dataAtOffset(-1, "a")})
+		require.EqualError(t, err, "data segment 0: offset must be a non-negative constant")
+	})
+}
@@ -2,6 +2,7 @@ package wasm
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"math"
@@ -207,7 +208,7 @@ func (s *Store) resolveImport(target *ModuleInstance, is *ImportSegment) error {
 	}
 	switch is.Desc.Kind {
 	case 0x00: // function
-		if err := s.applyFunctionImport(target, is.Desc.TypeIndexPtr, e); err != nil {
+		if err := s.applyFunctionImport(target, is, e); err != nil {
 			return fmt.Errorf("applyFunctionImport: %w", err)
 		}
 	case 0x01: // table
@@ -229,7 +230,8 @@ func (s *Store) resolveImport(target *ModuleInstance, is *ImportSegment) error {
 	return nil
 }
 
-func (s *Store) applyFunctionImport(target *ModuleInstance, typeIndexPtr *uint32, externModuleExportIsntance *ExportInstance) error {
+func (s *Store) applyFunctionImport(target *ModuleInstance, is *ImportSegment, externModuleExportIsntance *ExportInstance) error {
+	typeIndexPtr := is.Desc.TypeIndexPtr
 	if typeIndexPtr == nil {
 		return fmt.Errorf("type index is invalid")
 	}
@@ -239,10 +241,8 @@ func (s *Store) applyFunctionImport(target *ModuleInstance, typeIndexPtr *uint32
 		return fmt.Errorf("unknown type for function import")
 	}
 	iSig := target.Types[typeIndex]
-	if !HasSameSignature(iSig.ReturnTypes, f.Signature.ReturnTypes) {
-		return fmt.Errorf("return signature mimatch: %#x != %#x", iSig.ReturnTypes, f.Signature.ReturnTypes)
-	} else if !HasSameSignature(iSig.InputTypes, f.Signature.InputTypes) {
-		return fmt.Errorf("input signature mimatch: %#x != %#x", iSig.InputTypes, f.Signature.InputTypes)
+	if !HasSameSignature(iSig.ReturnTypes, f.Signature.ReturnTypes) || !HasSameSignature(iSig.InputTypes, f.Signature.InputTypes) {
+		return fmt.Errorf("import %q %q expects %s but export provides %s", is.Module, is.Name, iSig, f.Signature)
 	}
 	target.Functions = append(target.Functions, f)
 	return nil
@@ -317,7 +317,7 @@ func (s *Store) executeConstExpression(target *ModuleInstance, expr *ConstantExp
 		}
 		return v, ValueTypeI32, nil
 	case OptCodeI64Const:
-		v, _, err = leb128.DecodeInt32(r)
+		v, _, err = leb128.DecodeInt64(r)
 		if err != nil {
 			return nil, 0, fmt.Errorf("read uint64: %w", err)
 		}
@@ -366,7 +366,20 @@ func (s *Store) buildGlobalInstances(module *Module, target *ModuleInstance) (ro
 	rollbackFuncs = append(rollbackFuncs, func() {
 		s.Globals = s.Globals[:prevLen]
 	})
+	// Only imported globals exist in target at this point.
+	importedGlobalNum := uint32(len(target.Globals))
 	for _, gs := range module.GlobalSection {
+		if gs.Init.OptCode == OptCodeGlobalGet {
+			id, _, err := leb128.DecodeUint32(bytes.NewBuffer(gs.Init.Data))
+			if err != nil {
+				return rollbackFuncs, fmt.Errorf("read index of global: %w", err)
+			}
+			// Per the wasm 1.0 spec, global initializers may only reference imported immutable globals.
+			// See https://www.w3.org/TR/wasm-core-1/#constant-expressions%E2%91%A0
+			if id >= importedGlobalNum || target.Globals[id].Type.Mutable {
+				return rollbackFuncs, fmt.Errorf("constant expression may only reference imported immutable globals")
+			}
+		}
 		raw, t, err := s.executeConstExpression(target, gs.Init)
 		if err != nil {
 			return rollbackFuncs, fmt.Errorf("execution failed: %w", err)
@@ -670,6 +683,11 @@ func (s *Store) buildExportInstances(module *Module, target *ModuleInstance) (ro
 type valueTypeStack struct {
 	stack       []ValueType
 	stackLimits []int
+	// unchangedHeight and poppedAtInstruction keep the stack before the instruction being analyzed, to help debug
+	// validation errors. Copying the whole stack for every instruction would be wasteful, so only the values popped
+	// below unchangedHeight are saved, in the order they were popped.
+	unchangedHeight     int
+	poppedAtInstruction []ValueType
 }
 
 const (
@@ -689,7 +707,7 @@ func (s *valueTypeStack) pop() (ValueType, error) {
 		return valueTypeUnknown, nil
 	} else {
 		ret := s.stack[len(s.stack)-1]
-		s.stack = s.stack[:len(s.stack)-1]
+		s.truncate(len(s.stack) - 1)
 		return ret, nil
 	}
 }
@@ -700,7 +718,7 @@ func (s *valueTypeStack) popAndVerifyType(expected ValueType) error {
 		return err
 	}
 	if actual != expected && actual != valueTypeUnknown && expected != valueTypeUnknown {
-		return fmt.Errorf("type mismatch")
+		return fmt.Errorf("type mismatch: expected %s but got %s", valueTypeName(expected), valueTypeName(actual))
 	}
 	return nil
 }
@@ -716,10 +734,21 @@ func (s *valueTypeStack) unreachable() {
 
 func (s *valueTypeStack) resetAtStackLimit() {
 	if len(s.stackLimits) != 0 {
-		s.stack = s.stack[:s.stackLimits[len(s.stackLimits)-1]]
+		s.truncate(s.stackLimits[len(s.stackLimits)-1])
 	} else {
-		s.stack = []ValueType{}
+		s.truncate(0)
+	}
+}
+
+// truncate shortens the stack to the given height, saving any values the current instruction hadn't yet popped.
+func (s *valueTypeStack) truncate(height int) {
+	for i := s.unchangedHeight - 1; i >= height; i-- {
+		s.poppedAtInstruction = append(s.poppedAtInstruction, s.stack[i])
 	}
+	if height < s.unchangedHeight {
+		s.unchangedHeight = height
+	}
+	s.stack = s.stack[:height]
 }
 
 func (s *valueTypeStack) popStackLimit() {
@@ -737,36 +766,43 @@ func (s *valueTypeStack) popResults(expResults []ValueType, checkAboveLimit bool
 	if len(s.stackLimits) > 0 {
 		limit = s.stackLimits[len(s.stackLimits)-1]
 	}
+	if checkAboveLimit {
+		actual := len(s.stack) - limit
+		// If the stack is polymorphic (e.g. after unreachable), the unknown value stands in for any missing results.
+		polymorphic := actual > 0 && s.stack[limit] == valueTypeUnknown
+		if polymorphic {
+			actual--
+		}
+		if actual > len(expResults) || (!polymorphic && actual != len(expResults)) {
+			return fmt.Errorf("block leaves %d values but type expects %d", actual, len(expResults))
+		}
+	}
 	for _, exp := range expResults {
 		if err := s.popAndVerifyType(exp); err != nil {
 			return err
 		}
 	}
-	if checkAboveLimit {
-		if !(limit == len(s.stack) || (limit+1 == len(s.stack) && s.stack[limit] == valueTypeUnknown)) {
-			return fmt.Errorf("leftovers found in the stack")
-		}
-	}
 	return nil
 }
 
-func (s *valueTypeStack) String() string {
-	var typeStrs, limits []string
-	for _, v := range s.stack {
-		var str string
-		if v == valueTypeUnknown {
-			str = "unknown"
-		} else if v == ValueTypeI32 {
-			str = "i32"
-		} else if v == ValueTypeI64 {
-			str = "i64"
-		} else if v == ValueTypeF32 {
-			str = "f32"
-		} else if v == ValueTypeF64 {
-			str = "f64"
-		}
-		typeStrs = append(typeStrs, str)
+// startInstruction marks the stack before the next instruction changes it.
+func (s *valueTypeStack) startInstruction() {
+	s.unchangedHeight = len(s.stack)
+	s.poppedAtInstruction = s.poppedAtInstruction[:0]
+}
+
+// stackAtInstruction returns the stack as it was before the current instruction.
+func (s *valueTypeStack) stackAtInstruction() []ValueType {
+	ret := append([]ValueType{}, s.stack[:s.unchangedHeight]...)
+	for i := len(s.poppedAtInstruction) - 1; i >= 0; i-- {
+		ret = append(ret, s.poppedAtInstruction[i])
 	}
+	return ret
+}
+
+func (s *valueTypeStack) String() string {
+	var limits []string
+	typeStrs := valueTypeStrings(s.stack)
 	for _, d := range s.stackLimits {
 		limits = append(limits, fmt.Sprintf("%d", d))
 	}
@@ -782,12 +818,20 @@ func analyzeFunction(
 	globalDeclarations []*GlobalType,
 	memoryDeclarations []*MemoryType,
 	tableDeclarations []*TableType,
-) error {
+) (err error) {
 	labelStack := []*FunctionInstanceBlock{
 		{BlockType: f.Signature, StartAt: math.MaxUint64},
 	}
 	valueTypeStack := &valueTypeStack{}
+	inInstruction := true
+	defer func() {
+		if err != nil && inInstruction {
+			err = fmt.Errorf("%w; operand stack before the instruction: [%s]", err,
+				strings.Join(valueTypeStrings(valueTypeStack.stackAtInstruction()), ", "))
+		}
+	}()
 	for pc := uint64(0); pc < uint64(len(f.Body)); pc++ {
+		valueTypeStack.startInstruction()
 		op := f.Body[pc]
 		if OptCodeI32Load <= op && op <= OptCodeI64Store32 {
 			if len(memoryDeclarations) == 0 {
@@ -1562,6 +1606,7 @@ func analyzeFunction(
 			return fmt.Errorf("invalid instruction 0x%x", op)
 		}
 	}
+	inInstruction = false
 
 	if len(labelStack) > 0 {
 		return fmt.Errorf("ill-nested block exists")
@@ -1733,3 +1778,22 @@ func (s *Store) AddMemoryInstance(moduleName, name string, min uint32, max *uint
 	s.Memories = append(s.Memories, memory)
 	return nil
 }
+
+// ReadUint32 reads the little-endian uint32 at addr in the memory buffer.
+// Returns false if the four bytes starting at addr are out of range.
+func (m *MemoryInstance) ReadUint32(addr uint32) (uint32, bool) {
+	if uint64(addr)+4 > uint64(len(m.Buffer)) {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint32(m.Buffer[addr:]), true
+}
+
+// WriteUint32 writes v as a little-endian uint32 at addr in the memory buffer.
+// Returns false without writing if the four bytes starting at addr are out of range.
+func (m *MemoryInstance) WriteUint32(addr uint32, v uint32) bool {
+	if uint64(addr)+4 > uint64(len(m.Buffer)) {
+		return false
+	}
+	binary.LittleEndian.PutUint32(m.Buffer[addr:], v)
+	return true
+}
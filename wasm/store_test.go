@@ -0,0 +1,234 @@
+package wasm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/wazero/wasm/leb128"
+)
+
+func TestMemoryInstance_ReadUint32(t *testing.T) {
+	m := &MemoryInstance{Buffer: []byte{0x01, 0x02, 0x03, 0x04, 0x05}}
+
+	v, ok := m.ReadUint32(0)
+	require.True(t, ok)
+	require.Equal(t, uint32(0x04030201), v)
+
+	v, ok = m.ReadUint32(1)
+	require.True(t, ok)
+	require.Equal(t, uint32(0x05040302), v)
+
+	// Out of bounds.
+	_, ok = m.ReadUint32(2)
+	require.False(t, ok)
+	_, ok = m.ReadUint32(0xffffffff)
+	require.False(t, ok)
+}
+
+func TestMemoryInstance_WriteUint32(t *testing.T) {
+	m := &MemoryInstance{Buffer: make([]byte, 5)}
+
+	require.True(t, m.WriteUint32(1, 0x04030201))
+	require.Equal(t, []byte{0x00, 0x01, 0x02, 0x03, 0x04}, m.Buffer)
+
+	// Out of bounds must not modify the buffer.
+	require.False(t, m.WriteUint32(2, 0xffffffff))
+	require.False(t, m.WriteUint32(0xffffffff, 0xffffffff))
+	require.Equal(t, []byte{0x00, 0x01, 0x02, 0x03, 0x04}, m.Buffer)
+}
+
+func TestStore_executeConstExpression_I64(t *testing.T) {
+	s := NewStore(nil)
+	for _, c := range []struct {
+		data     []byte
+		expected int64
+	}{
+		{data: leb128.EncodeInt64(math.MinInt64), expected: math.MinInt64},
+		{data: leb128.EncodeInt64(math.MaxInt64), expected: math.MaxInt64},
+		// 18446744073709551615 is the unsigned form of -1.
+		{data: []byte{0x7f}, expected: -1},
+		{data: leb128.EncodeInt64(math.MaxInt32 + 1), expected: math.MaxInt32 + 1},
+	} {
+		expr := &ConstantExpression{OptCode: OptCodeI64Const, Data: c.data}
+		v, vt, err := s.executeConstExpression(&ModuleInstance{}, expr)
+		require.NoError(t, err)
+		require.Equal(t, ValueTypeI64, vt)
+		require.Equal(t, c.expected, v)
+	}
+}
+
+func TestStore_buildGlobalInstances_GlobalGet(t *testing.T) {
+	globalGet := func(index uint32) *GlobalSegment {
+		return &GlobalSegment{
+			Type: &GlobalType{ValType: ValueTypeI32},
+			Init: &ConstantExpression{OptCode: OptCodeGlobalGet, Data: leb128.EncodeUint32(index)},
+		}
+	}
+	i32Const := &GlobalSegment{
+		Type: &GlobalType{ValType: ValueTypeI32},
+		Init: &ConstantExpression{OptCode: OptCodeI32Const, Data: leb128.EncodeInt32(1)},
+	}
+
+	t.Run("imported immutable", func(t *testing.T) {
+		s := NewStore(nil)
+		target := &ModuleInstance{Globals: []*GlobalInstance{{Type: &GlobalType{ValType: ValueTypeI32}, Val: 42}}}
+		_, err := s.buildGlobalInstances(&Module{GlobalSection: []*GlobalSegment{globalGet(0)}}, target)
+		require.NoError(t, err)
+		require.Len(t, target.Globals, 2)
+		require.Equal(t, uint64(42), target.Globals[1].Val)
+	})
+	t.Run("imported mutable", func(t *testing.T) {
+		s := NewStore(nil)
+		target := &ModuleInstance{Globals: []*GlobalInstance{{Type: &GlobalType{ValType: ValueTypeI32, Mutable: true}}}}
+		_, err := s.buildGlobalInstances(&Module{GlobalSection: []*GlobalSegment{globalGet(0)}}, target)
+		require.EqualError(t, err, "constant expression may only reference imported immutable globals")
+	})
+	t.Run("locally defined", func(t *testing.T) {
+		s := NewStore(nil)
+		target := &ModuleInstance{}
+		_, err := s.buildGlobalInstances(&Module{GlobalSection: []*GlobalSegment{i32Const, globalGet(0)}}, target)
+		require.EqualError(t, err, "constant expression may only reference imported immutable globals")
+	})
+}
+
+func TestAnalyzeFunction_Errors(t *testing.T) {
+	for _, c := range []struct {
+		name        string
+		body        []byte
+		expectedErr string
+	}{
+		{
+			name: "valid",
+			body: []byte{
+				OptCodeBlock, 0x7f, // (block (result i32)
+				OptCodeI32Const, 0x01,
+				OptCodeEnd, // )
+				OptCodeDrop,
+				OptCodeEnd,
+			},
+		},
+		{
+			name: "polymorphic",
+			body: []byte{
+				OptCodeBlock, 0x7f, // (block (result i32)
+				OptCodeUnreachable,
+				OptCodeEnd, // )
+				OptCodeDrop,
+				OptCodeEnd,
+			},
+		},
+		{
+			name: "too many",
+			body: []byte{
+				OptCodeBlock, 0x7f, // (block (result i32)
+				OptCodeI32Const, 0x01,
+				OptCodeI32Const, 0x02,
+				OptCodeEnd, // )
+				OptCodeDrop,
+				OptCodeEnd,
+			},
+			expectedErr: "invalid instruction results at end instruction; expected [127]: block leaves 2 values but type expects 1; operand stack before the instruction: [i32, i32]",
+		},
+		{
+			name: "too few",
+			body: []byte{
+				OptCodeBlock, 0x7f, // (block (result i32)
+				OptCodeEnd, // )
+				OptCodeDrop,
+				OptCodeEnd,
+			},
+			expectedErr: "invalid instruction results at end instruction; expected [127]: block leaves 0 values but type expects 1; operand stack before the instruction: []",
+		},
+		{
+			name: "operand type mismatch",
+			body: []byte{
+				OptCodeF32Const, 0x00, 0x00, 0x00, 0x00,
+				OptCodeI32Const, 0x01,
+				OptCodeI32add,
+				OptCodeDrop,
+				OptCodeEnd,
+			},
+			expectedErr: "cannot pop the 2nd i32 operand for 0x6a: type mismatch: expected i32 but got f32; operand stack before the instruction: [f32, i32]",
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			f := &FunctionInstance{
+				Signature: &FunctionType{},
+				Body:      c.body,
+				Blocks:    map[uint64]*FunctionInstanceBlock{},
+			}
+			err := analyzeFunction(&Module{}, f, nil, nil, nil, nil)
+			if c.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, c.expectedErr)
+			}
+		})
+	}
+}
+
+func TestValueTypeStack_stackAtInstruction(t *testing.T) {
+	s := &valueTypeStack{}
+	s.push(ValueTypeI32)
+	s.push(ValueTypeI64)
+	s.startInstruction()
+	require.Equal(t, []ValueType{ValueTypeI32, ValueTypeI64}, s.stackAtInstruction())
+
+	// Values pushed over popped ones, or removed by unreachable, are still restored.
+	_, err := s.pop()
+	require.NoError(t, err)
+	s.push(ValueTypeF32)
+	s.push(ValueTypeF64)
+	s.unreachable()
+	require.Equal(t, []ValueType{ValueTypeI32, ValueTypeI64}, s.stackAtInstruction())
+
+	s.startInstruction()
+	require.Equal(t, []ValueType{valueTypeUnknown}, s.stackAtInstruction())
+}
+
+func TestStore_resolveImport_FunctionSignature(t *testing.T) {
+	typeIndex := uint32(0)
+	is := &ImportSegment{Module: "env", Name: "log", Desc: &ImportDesc{Kind: ImportKindFunction, TypeIndexPtr: &typeIndex}}
+	newStore := func(exported *FunctionType) *Store {
+		return &Store{ModuleInstances: map[string]*ModuleInstance{
+			"env": {Exports: map[string]*ExportInstance{
+				"log": {Kind: ExportKindFunction, Function: &FunctionInstance{Signature: exported}},
+			}},
+		}}
+	}
+
+	t.Run("match", func(t *testing.T) {
+		s := newStore(&FunctionType{InputTypes: []ValueType{ValueTypeI32}})
+		target := &ModuleInstance{Types: []*FunctionType{{InputTypes: []ValueType{ValueTypeI32}}}}
+		require.NoError(t, s.resolveImport(target, is))
+		require.Equal(t, 1, len(target.Functions))
+	})
+
+	for _, c := range []struct {
+		name        string
+		exported    *FunctionType
+		expectedErr string
+	}{
+		{
+			name:        "input mismatch",
+			exported:    &FunctionType{InputTypes: []ValueType{ValueTypeI64}},
+			expectedErr: `applyFunctionImport: import "env" "log" expects [i32]->[] but export provides [i64]->[]`,
+		},
+		{
+			name:        "return mismatch",
+			exported:    &FunctionType{InputTypes: []ValueType{ValueTypeI32}, ReturnTypes: []ValueType{ValueTypeF32}},
+			expectedErr: `applyFunctionImport: import "env" "log" expects [i32]->[] but export provides [i32]->[f32]`,
+		},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			s := newStore(c.exported)
+			target := &ModuleInstance{Types: []*FunctionType{{InputTypes: []ValueType{ValueTypeI32}}}}
+			require.EqualError(t, s.resolveImport(target, is), c.expectedErr)
+			require.Empty(t, target.Functions)
+		})
+	}
+}
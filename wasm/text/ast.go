@@ -0,0 +1,145 @@
+package text
+
+import "github.com/tetratelabs/wazero/wasm"
+
+// Module is the abstract syntax tree of a module in the WebAssembly text format, as returned by Parse. Symbolic ids,
+// such as "$main", are kept for tools, but every reference to them is resolved to an index.
+//
+// See https://www.w3.org/TR/wasm-core-1/#text-module
+type Module struct {
+	// ID is the optional symbolic name of the module, ex. "$m".
+	ID string
+	// Types are the function signatures of the module, which include both type fields in the order they are
+	// defined, and then any inline signatures of funcs that don't match one.
+	Types []*FuncType
+	// Memories are the memory fields in the order they are defined, which is their index.
+	Memories []*Memory
+	// Funcs are the func fields in the order they are defined, which is their index.
+	Funcs []*Func
+	// Data are the data fields in the order they are defined.
+	Data []*Data
+	// Start is the index of the func called when the module is instantiated, or nil if there isn't a start field.
+	Start *uint32
+	// Exports are the export fields in the order they are defined.
+	Exports []*Export
+}
+
+// Export is an export field, ex. "(export "main" (func $main))".
+//
+// See https://www.w3.org/TR/wasm-core-1/#text-export
+type Export struct {
+	// Name is the decoded name of the export.
+	Name string
+	// Kind is wasm.ExportKindFunction or wasm.ExportKindMemory.
+	Kind wasm.ExportKind
+	// Index is the index of the func or memory exported.
+	Index uint32
+	// Line and Col are the position of the "export" keyword.
+	Line, Col int
+}
+
+// FuncType is a function signature, either from a type field, ex. "(type $t (func (param i32) (result i32)))", or
+// the inline params and results of a func.
+//
+// See https://www.w3.org/TR/wasm-core-1/#text-type
+type FuncType struct {
+	// ID is the optional symbolic name of the type, ex. "$t".
+	ID string
+	// Params are the types of the parameters, or nil if there are none.
+	Params []wasm.ValueType
+	// Results are the types of the results, or nil if there are none.
+	Results []wasm.ValueType
+}
+
+// String returns the signature in the same format as wasm.FunctionType, ex. "[i32 i32]->[i32]".
+func (t *FuncType) String() string {
+	return (&wasm.FunctionType{InputTypes: t.Params, ReturnTypes: t.Results}).String()
+}
+
+// equal returns true if the types have the same params and results, regardless of their ids.
+func (t *FuncType) equal(o *FuncType) bool {
+	return wasm.HasSameSignature(t.Params, o.Params) && wasm.HasSameSignature(t.Results, o.Results)
+}
+
+// Memory is a memory field, ex. "(memory 1)".
+//
+// See https://www.w3.org/TR/wasm-core-1/#text-mem
+type Memory struct {
+	// ID is the optional symbolic name of the memory, ex. "$mem".
+	ID string
+	// Min is the initial size of the memory in pages.
+	Min uint32
+	// Max is the maximum size of the memory in pages, or nil if it is unbounded.
+	Max *uint32
+	// Line and Col are the position of the "memory" keyword.
+	Line, Col int
+}
+
+// Func is a func field, ex. "(func $main (local i32) (local.set 0 (i32.const 1)))".
+//
+// See https://www.w3.org/TR/wasm-core-1/#text-func
+type Func struct {
+	// ID is the optional symbolic name of the function, ex. "$main".
+	ID string
+	// Type is the index of the function's signature in Module.Types.
+	Type uint32
+	// Params are the parameters of the function, which are the first locals. Only inline params can have an ID.
+	Params []*Local
+	// Locals are the local variables in the order they are declared. The index of the first is the count of Params.
+	Locals []*Local
+	// Body are the instructions of the function, not including the implicit "end".
+	Body []*Instruction
+	// Line and Col are the position of the "func" keyword.
+	Line, Col int
+}
+
+// Local is a parameter or local variable of a Func.
+type Local struct {
+	// ID is the optional symbolic name of the local, ex. "$i".
+	ID string
+	// Type is the type of the local, ex. wasm.ValueTypeI32.
+	Type wasm.ValueType
+}
+
+// Data is a data field, which initializes part of a memory, ex. "(data (i32.const 0) "hello")".
+//
+// See https://www.w3.org/TR/wasm-core-1/#text-dat
+type Data struct {
+	// Memory is the index of the memory to initialize, which is zero unless specified.
+	Memory uint32
+	// Offset is the constant expression for the byte offset to copy Init to, ex. (i32.const 0).
+	Offset []*Instruction
+	// Init are the bytes of all strings in the field, decoded and concatenated.
+	Init []byte
+	// Line and Col are the position of the "data" keyword.
+	Line, Col int
+}
+
+// Instruction is an instruction in either the plain or folded form. The folded form "(i32.eqz (local.get 0))" has the
+// same Instruction as the plain form "local.get 0 i32.eqz", except the local.get is an Operand of the i32.eqz.
+//
+// See https://www.w3.org/TR/wasm-core-1/#text-instr
+type Instruction struct {
+	// OptCode is the opcode of the instruction, ex. wasm.OptCodeI32Const.
+	OptCode wasm.OptCode
+	// Immediates are the values following the keyword, in the order they are encoded, ex. the value of an i32.const
+	// or the index of a local.get. References to ids are resolved to indices, and labels to relative depths.
+	//
+	// Signed integers are sign-extended, ex. (i32.const -1) is 0xffff_ffff_ffff_ffff. Floats are their IEEE 754 bits.
+	// A memory instruction has the alignment as a power of two, then its offset, as in the binary format. A block or
+	// loop has its result type, or blockTypeEmpty.
+	Immediates []uint64
+	// Operands are the instructions folded into this one, which are executed before it.
+	Operands []*Instruction
+	// Label is the optional symbolic name of a block or loop, ex. "$loop".
+	Label string
+	// Body are the instructions inside a block or loop, not including the "end".
+	Body []*Instruction
+	// Line and Col are the position of the instruction's keyword.
+	Line, Col int
+}
+
+// blockTypeEmpty is the block type immediate of a block or loop that has no result.
+//
+// See https://www.w3.org/TR/wasm-core-1/#binary-blocktype
+const blockTypeEmpty = 0x40
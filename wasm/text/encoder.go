@@ -0,0 +1,286 @@
+package text
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/tetratelabs/wazero/wasm"
+	"github.com/tetratelabs/wazero/wasm/leb128"
+)
+
+// magic and version are the first bytes of every module in the binary format.
+// See https://www.w3.org/TR/wasm-core-1/#binary-module
+var magic, version = []byte("\x00asm"), []byte{0x01, 0x00, 0x00, 0x00}
+
+// Section IDs of the binary format in the order they must be encoded.
+// See https://www.w3.org/TR/wasm-core-1/#sections%E2%91%A0
+const (
+	sectionIDCustom   = 0
+	sectionIDType     = 1
+	sectionIDFunction = 3
+	sectionIDMemory   = 5
+	sectionIDExport   = 7
+	sectionIDStart    = 8
+	sectionIDCode     = 10
+	sectionIDData     = 11
+)
+
+// EncodeBinary returns the module in the binary format, which can be decoded with wasm.DecodeModule. Sections that
+// would be empty are omitted. This returns an error if an index is out of range, ex. an export of a func that doesn't
+// exist or a local.get of a local that doesn't. The module isn't otherwise validated, ex. the types of operands.
+//
+// See https://www.w3.org/TR/wasm-core-1/#binary-format%E2%91%A0
+func (m *Module) EncodeBinary() ([]byte, error) {
+	return m.EncodeBinaryWithOptions(EncodeOptions{})
+}
+
+// EncodeOptions configure EncodeBinaryWithOptions. The zero value encodes only the sections defined by the module.
+type EncodeOptions struct {
+	// LineInfo adds a custom section named LineInfoSectionName after the data section, with the same contents as
+	// Module.EncodeLineInfo.
+	LineInfo bool
+}
+
+// EncodeBinaryWithOptions is like EncodeBinary, except it can add custom sections.
+func (m *Module) EncodeBinaryWithOptions(opts EncodeOptions) ([]byte, error) {
+	ret := append(append([]byte{}, magic...), version...)
+
+	if len(m.Types) > 0 {
+		contents := leb128.EncodeUint32(uint32(len(m.Types)))
+		for _, t := range m.Types {
+			contents = append(contents, 0x60) // functype
+			contents = appendValueTypes(contents, t.Params)
+			contents = appendValueTypes(contents, t.Results)
+		}
+		ret = appendSection(ret, sectionIDType, contents)
+	}
+
+	if len(m.Funcs) > 0 {
+		contents := leb128.EncodeUint32(uint32(len(m.Funcs)))
+		for i, f := range m.Funcs {
+			if f.Type >= uint32(len(m.Types)) {
+				return nil, fmt.Errorf("func[%d]: type index %d out of range of %d", i, f.Type, len(m.Types))
+			}
+			contents = append(contents, leb128.EncodeUint32(f.Type)...)
+		}
+		ret = appendSection(ret, sectionIDFunction, contents)
+	}
+
+	if len(m.Memories) > 0 {
+		contents := leb128.EncodeUint32(uint32(len(m.Memories)))
+		for _, mem := range m.Memories {
+			if mem.Max == nil {
+				contents = append(contents, 0x00)
+				contents = append(contents, leb128.EncodeUint32(mem.Min)...)
+			} else {
+				contents = append(contents, 0x01)
+				contents = append(contents, leb128.EncodeUint32(mem.Min)...)
+				contents = append(contents, leb128.EncodeUint32(*mem.Max)...)
+			}
+		}
+		ret = appendSection(ret, sectionIDMemory, contents)
+	}
+
+	if len(m.Exports) > 0 {
+		contents := leb128.EncodeUint32(uint32(len(m.Exports)))
+		for _, e := range m.Exports {
+			count := len(m.Funcs)
+			if e.Kind == wasm.ExportKindMemory {
+				count = len(m.Memories)
+			}
+			if e.Index >= uint32(count) {
+				return nil, fmt.Errorf("export %q: index %d out of range of %d", e.Name, e.Index, count)
+			}
+			contents = append(contents, leb128.EncodeUint32(uint32(len(e.Name)))...)
+			contents = append(contents, e.Name...)
+			contents = append(contents, e.Kind)
+			contents = append(contents, leb128.EncodeUint32(e.Index)...)
+		}
+		ret = appendSection(ret, sectionIDExport, contents)
+	}
+
+	if m.Start != nil {
+		if *m.Start >= uint32(len(m.Funcs)) {
+			return nil, fmt.Errorf("start: function index %d out of range of %d", *m.Start, len(m.Funcs))
+		}
+		ret = appendSection(ret, sectionIDStart, leb128.EncodeUint32(*m.Start))
+	}
+
+	var funcLines []*FuncLineInfo
+	if len(m.Funcs) > 0 {
+		contents := leb128.EncodeUint32(uint32(len(m.Funcs)))
+		for i, f := range m.Funcs {
+			var lines *[]*LineInfo
+			if opts.LineInfo {
+				funcLines = append(funcLines, &FuncLineInfo{Func: uint32(i)})
+				lines = &funcLines[i].Lines
+			}
+			body, err := m.encodeFuncBody(f, lines)
+			if err != nil {
+				return nil, fmt.Errorf("func[%d]: %w", i, err)
+			}
+			contents = append(contents, leb128.EncodeUint32(uint32(len(body)))...)
+			contents = append(contents, body...)
+		}
+		ret = appendSection(ret, sectionIDCode, contents)
+	}
+
+	if len(m.Data) > 0 {
+		contents := leb128.EncodeUint32(uint32(len(m.Data)))
+		for i, d := range m.Data {
+			if d.Memory >= uint32(len(m.Memories)) {
+				return nil, fmt.Errorf("data[%d]: memory index %d out of range of %d", i, d.Memory, len(m.Memories))
+			}
+			contents = append(contents, leb128.EncodeUint32(d.Memory)...)
+			var err error
+			if contents, err = (&instructionEncoder{m: m}).encode(contents, d.Offset); err != nil {
+				return nil, fmt.Errorf("data[%d]: %w", i, err)
+			}
+			contents = append(contents, wasm.OptCodeEnd)
+			contents = append(contents, leb128.EncodeUint32(uint32(len(d.Init)))...)
+			contents = append(contents, d.Init...)
+		}
+		ret = appendSection(ret, sectionIDData, contents)
+	}
+
+	if opts.LineInfo {
+		contents := leb128.EncodeUint32(uint32(len(LineInfoSectionName)))
+		contents = append(contents, LineInfoSectionName...)
+		contents = append(contents, encodeLineInfo(funcLines)...)
+		ret = appendSection(ret, sectionIDCustom, contents)
+	}
+	return ret, nil
+}
+
+// appendSection appends the section ID, the size of the contents and the contents.
+func appendSection(ret []byte, id byte, contents []byte) []byte {
+	ret = append(ret, id)
+	ret = append(ret, leb128.EncodeUint32(uint32(len(contents)))...)
+	return append(ret, contents...)
+}
+
+func appendValueTypes(ret []byte, types []wasm.ValueType) []byte {
+	ret = append(ret, leb128.EncodeUint32(uint32(len(types)))...)
+	return append(ret, types...)
+}
+
+// encodeFuncBody returns the func as it is in the code section of the binary format, without the size prefix. Unless
+// lines is nil, the LineInfo of each instruction is appended to it, relative to the beginning of the result.
+//
+// See https://www.w3.org/TR/wasm-core-1/#binary-func
+func (m *Module) encodeFuncBody(f *Func, lines *[]*LineInfo) ([]byte, error) {
+	// Runs of the same type are compressed into one count, ex. three i32 locals are (3, i32).
+	var runs [][2]uint32
+	for _, l := range f.Locals {
+		if n := len(runs); n > 0 && runs[n-1][1] == uint32(l.Type) {
+			runs[n-1][0]++
+		} else {
+			runs = append(runs, [2]uint32{1, uint32(l.Type)})
+		}
+	}
+	body := leb128.EncodeUint32(uint32(len(runs)))
+	for _, r := range runs {
+		body = append(body, leb128.EncodeUint32(r[0])...)
+		body = append(body, byte(r[1]))
+	}
+
+	e := &instructionEncoder{m: m, localCount: uint32(len(f.Params) + len(f.Locals)), lines: lines}
+	body, err := e.encode(body, f.Body)
+	if err != nil {
+		return nil, err
+	}
+	return append(body, wasm.OptCodeEnd), nil
+}
+
+// instructionEncoder encodes the instructions of a func body or constant expression, checking that their indices are
+// in range.
+type instructionEncoder struct {
+	m *Module
+	// localCount is the count of params and locals of the func, or zero in a constant expression.
+	localCount uint32
+	// depth is the count of blocks and loops enclosing the instructions being encoded.
+	depth uint32
+	// lines receives the LineInfo of each instruction, unless nil.
+	lines *[]*LineInfo
+}
+
+// encode appends the instructions to the body, in the order they execute. Operands of a folded instruction come
+// before it, and the body of a block or loop follows it, until an "end".
+func (e *instructionEncoder) encode(body []byte, instructions []*Instruction) ([]byte, error) {
+	var err error
+	for _, inst := range instructions {
+		if body, err = e.encode(body, inst.Operands); err != nil {
+			return nil, err
+		}
+		if err = e.checkIndex(inst); err != nil {
+			return nil, err
+		}
+		if e.lines != nil {
+			*e.lines = append(*e.lines, &LineInfo{Offset: uint32(len(body)), Line: uint32(inst.Line), Col: uint32(inst.Col)})
+		}
+		body = append(body, inst.OptCode)
+
+		switch op := inst.OptCode; op {
+		case wasm.OptCodeBlock, wasm.OptCodeLoop:
+			body = append(body, byte(inst.Immediates[0]))
+			e.depth++
+			if body, err = e.encode(body, inst.Body); err != nil {
+				return nil, err
+			}
+			e.depth--
+			body = append(body, wasm.OptCodeEnd)
+		case wasm.OptCodeI32Const:
+			body = append(body, leb128.EncodeInt32(int32(inst.Immediates[0]))...)
+		case wasm.OptCodeI64Const:
+			body = append(body, leb128.EncodeInt64(int64(inst.Immediates[0]))...)
+		case wasm.OptCodeF32Const:
+			var b [4]byte
+			binary.LittleEndian.PutUint32(b[:], uint32(inst.Immediates[0]))
+			body = append(body, b[:]...)
+		case wasm.OptCodeF64Const:
+			var b [8]byte
+			binary.LittleEndian.PutUint64(b[:], inst.Immediates[0])
+			body = append(body, b[:]...)
+		case wasm.OptCodeMemorySize, wasm.OptCodeMemoryGrow:
+			body = append(body, 0x00) // the memory index, which is always zero in WebAssembly 1.0
+		default:
+			// The remaining immediates are indices, or the alignment and offset of a memory instruction.
+			for _, v := range inst.Immediates {
+				body = append(body, leb128.EncodeUint32(uint32(v))...)
+			}
+		}
+	}
+	return body, nil
+}
+
+// checkIndex returns an error if the index immediate of the instruction is out of range, or it needs a memory and
+// the module has none.
+func (e *instructionEncoder) checkIndex(inst *Instruction) error {
+	var kind string
+	var count uint32
+	switch op := inst.OptCode; op {
+	case wasm.OptCodeLocalGet, wasm.OptCodeLocalSet, wasm.OptCodeLocalTee:
+		kind, count = "local", e.localCount
+	case wasm.OptCodeBr, wasm.OptCodeBrIf:
+		kind, count = "label", e.depth+1 // the func body is the outermost label
+	case wasm.OptCodeCall:
+		kind, count = "func", uint32(len(e.m.Funcs))
+	case wasm.OptCodeMemorySize, wasm.OptCodeMemoryGrow:
+		kind, count = "memory", uint32(len(e.m.Memories))
+	default:
+		if op < wasm.OptCodeI32Load || op > wasm.OptCodeI64Store32 {
+			return nil
+		}
+		kind, count = "memory", uint32(len(e.m.Memories))
+	}
+	// Memory instructions implicitly use memory zero.
+	index := uint64(0)
+	if kind != "memory" {
+		index = inst.Immediates[0]
+	}
+	if index >= uint64(count) {
+		name, _ := wasm.OptCodeName(inst.OptCode)
+		return fmt.Errorf("%d:%d %s: %s index %d out of range of %d", inst.Line, inst.Col, name, kind, index, count)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package text
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/wazero/wasm"
+	"github.com/tetratelabs/wazero/wasm/naivevm"
+)
+
+func TestModule_EncodeBinary_Example(t *testing.T) {
+	m, err := Parse(exampleWat)
+	require.NoError(t, err)
+	actual, err := m.EncodeBinary()
+	require.NoError(t, err)
+
+	// Sections are in the order of the binary format, each prefixed by its ID and size.
+	require.Equal(t, []byte{
+		0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, // magic and version
+		0x01, 0x04, 0x01, 0x60, 0x00, 0x00, // type section: [] -> []
+		0x03, 0x02, 0x01, 0x00, // function section: $main has type 0
+		0x05, 0x03, 0x01, 0x00, 0x01, // memory section: min 1
+		0x08, 0x01, 0x00, // start section: $main
+		0x0a, 0x2e, 0x01, 0x2c, // code section: one body of 44 bytes
+		0x01, 0x03, 0x7f, // local i32 i32 i32
+		0x41, 0x00, 0x21, 0x00, 0x41, 0x01, 0x21, 0x01, 0x41, 0x0a, 0x21, 0x02,
+		0x03, 0x40, // loop
+		0x20, 0x00, 0x20, 0x01, 0x22, 0x00, 0x6a, 0x21, 0x01,
+		0x20, 0x02, 0x41, 0x01, 0x6b, 0x22, 0x02, 0x0d, 0x00,
+		0x0b, // end of loop
+		0x41, 0x00, 0x20, 0x00, 0x36, 0x02, 0x00,
+		0x0b, // end of func
+	}, actual)
+
+	mod, err := wasm.DecodeModule(actual)
+	require.NoError(t, err)
+	store := wasm.NewStore(naivevm.NewEngine())
+	require.NoError(t, store.Instantiate(mod, "test"))
+	// The start function stored the 10th Fibonacci number.
+	require.Equal(t, []byte{55, 0, 0, 0}, store.ModuleInstances["test"].Memory.Buffer[0:4])
+}
+
+func TestModule_EncodeBinary(t *testing.T) {
+	header := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	tests := []struct {
+		name     string
+		input    string
+		expected []byte
+	}{
+		{name: "empty", input: "(module)", expected: header},
+		{
+			name:  "type with params and results",
+			input: "(module (type (func (param i32 i64) (result f32))))",
+			expected: append(header,
+				0x01, 0x07, 0x01, 0x60, 0x02, 0x7f, 0x7e, 0x01, 0x7d,
+			),
+		},
+		{
+			name:  "memory with max",
+			input: "(module (memory 1 0x10000))",
+			expected: append(header,
+				0x05, 0x06, 0x01, 0x01, 0x01, 0x80, 0x80, 0x04,
+			),
+		},
+		{
+			name:  "exports",
+			input: `(module (memory $mem 1) (func $f) (export "f" (func $f)) (export "mem" (memory $mem)))`,
+			expected: append(header,
+				0x01, 0x04, 0x01, 0x60, 0x00, 0x00,
+				0x03, 0x02, 0x01, 0x00,
+				0x05, 0x03, 0x01, 0x00, 0x01,
+				0x07, 0x0b, 0x02, 0x01, 'f', 0x00, 0x00, 0x03, 'm', 'e', 'm', 0x02, 0x00,
+				0x0a, 0x04, 0x01, 0x02, 0x00, 0x0b,
+			),
+		},
+		{
+			name:  "data",
+			input: `(module (memory 1) (data (i32.const 8) "hi"))`,
+			expected: append(header,
+				0x05, 0x03, 0x01, 0x00, 0x01,
+				0x0b, 0x08, 0x01, 0x00, 0x41, 0x08, 0x0b, 0x02, 'h', 'i',
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := Parse([]byte(tc.input))
+			require.NoError(t, err)
+			actual, err := m.EncodeBinary()
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, actual)
+
+			_, err = wasm.DecodeModule(actual)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestModule_EncodeBinary_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       *Module
+		expectedErr string
+	}{
+		{
+			name:        "export func out of range",
+			input:       &Module{Exports: []*Export{{Name: "f", Kind: wasm.ExportKindFunction}}},
+			expectedErr: `export "f": index 0 out of range of 0`,
+		},
+		{
+			name:        "export memory out of range",
+			input:       &Module{Memories: []*Memory{{}}, Exports: []*Export{{Name: "m", Kind: wasm.ExportKindMemory, Index: 1}}},
+			expectedErr: `export "m": index 1 out of range of 1`,
+		},
+		{
+			name:        "func type out of range",
+			input:       &Module{Funcs: []*Func{{Type: 1}}, Types: []*FuncType{{}}},
+			expectedErr: "func[0]: type index 1 out of range of 1",
+		},
+		{
+			name:        "start out of range",
+			input:       &Module{Start: new(uint32)},
+			expectedErr: "start: function index 0 out of range of 0",
+		},
+		{
+			name:        "data memory out of range",
+			input:       &Module{Data: []*Data{{}}},
+			expectedErr: "data[0]: memory index 0 out of range of 0",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.input.EncodeBinary()
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestModule_EncodeBinary_InstructionErrors(t *testing.T) {
+	tests := []struct {
+		name, input string
+		expectedErr string
+	}{
+		{name: "local", input: "(module (func (param i32) local.get 1))", expectedErr: "func[0]: 1:27 local.get: local index 1 out of range of 1"},
+		{name: "label", input: "(module (func (block br 2)))", expectedErr: "func[0]: 1:22 br: label index 2 out of range of 2"},
+		{name: "func", input: "(module (func call 1))", expectedErr: "func[0]: 1:15 call: func index 1 out of range of 1"},
+		{name: "memory", input: "(module (func (i32.load (i32.const 0))))", expectedErr: "func[0]: 1:16 i32.load: memory index 0 out of range of 0"},
+		{name: "memory.size", input: "(module (func memory.size drop))", expectedErr: "func[0]: 1:15 memory.size: memory index 0 out of range of 0"},
+		{name: "operand", input: "(module (func (drop (local.get 0))))", expectedErr: "func[0]: 1:22 local.get: local index 0 out of range of 0"},
+		{name: "data offset", input: `(module (memory 1) (data (local.get 0) ""))`, expectedErr: "data[0]: 1:27 local.get: local index 0 out of range of 0"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := Parse([]byte(tc.input))
+			require.NoError(t, err)
+			_, err = m.EncodeBinary()
+			require.EqualError(t, err, tc.expectedErr)
+			if len(m.Funcs) > 0 {
+				_, err = m.EncodeLineInfo()
+				require.EqualError(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
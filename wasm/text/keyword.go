@@ -0,0 +1,55 @@
+package text
+
+import "github.com/tetratelabs/wazero/wasm"
+
+// KeywordID identifies an instruction keyword. It is the wasm.OptCode of the instruction, ex. wasm.OptCodeI32Const for
+// "i32.const", so a parser can switch on it instead of comparing text, and later encode it as-is.
+type KeywordID = wasm.OptCode
+
+// keywordTableSize is a power of two more than twice the count of instruction keywords, so that probes are short.
+const keywordTableSize = 512
+
+type keywordEntry struct {
+	keyword string
+	id      KeywordID
+}
+
+// keywordTable is an open addressing hash table of all WebAssembly 1.0 instruction keywords. An empty keyword ends a
+// probe sequence.
+var keywordTable = func() (ret [keywordTableSize]keywordEntry) {
+	for op := 0; op < 256; op++ {
+		keyword, ok := wasm.OptCodeName(KeywordID(op))
+		if !ok {
+			continue
+		}
+		i := keywordHash([]byte(keyword))
+		for ret[i].keyword != "" {
+			i = (i + 1) & (keywordTableSize - 1)
+		}
+		ret[i] = keywordEntry{keyword: keyword, id: KeywordID(op)}
+	}
+	return
+}()
+
+// InternKeyword returns the KeywordID of the TokenKeyword at source[beginPos:endPos], or false if it isn't an
+// instruction keyword, ex. "module" or "i32". This doesn't allocate.
+//
+// See https://www.w3.org/TR/wasm-core-1/#instructions%E2%91%A6
+func InternKeyword(source []byte, beginPos, endPos int) (KeywordID, bool) {
+	keyword := source[beginPos:endPos]
+	for i := keywordHash(keyword); keywordTable[i].keyword != ""; i = (i + 1) & (keywordTableSize - 1) {
+		if keywordTable[i].keyword == string(keyword) { // the conversion doesn't allocate when comparing
+			return keywordTable[i].id, true
+		}
+	}
+	return 0, false
+}
+
+// keywordHash returns the FNV-1a hash of the keyword, masked to an index of keywordTable.
+func keywordHash(keyword []byte) uint32 {
+	h := uint32(2166136261)
+	for _, b := range keyword {
+		h = (h ^ uint32(b)) * 16777619
+	}
+	return h & (keywordTableSize - 1)
+}
@@ -0,0 +1,73 @@
+package text
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/wazero/wasm"
+)
+
+func TestInternKeyword(t *testing.T) {
+	count := 0
+	for op := 0; op < 256; op++ {
+		keyword, ok := wasm.OptCodeName(KeywordID(op))
+		if !ok {
+			continue
+		}
+		count++
+		actual, ok := InternKeyword([]byte(keyword), 0, len(keyword))
+		require.True(t, ok, keyword)
+		require.Equal(t, KeywordID(op), actual, keyword)
+	}
+	require.Equal(t, 172, count) // all WebAssembly 1.0 instructions
+
+	source := []byte("(i32.const 1)")
+	actual, ok := InternKeyword(source, 1, 10)
+	require.True(t, ok)
+	require.Equal(t, wasm.OptCodeI32Const, actual)
+}
+
+func TestInternKeyword_NotInstruction(t *testing.T) {
+	for _, keyword := range []string{"", "module", "func", "i32", "i32.", "i32.const1", "I32.CONST", "nop nop"} {
+		_, ok := InternKeyword([]byte(keyword), 0, len(keyword))
+		require.False(t, ok, keyword)
+	}
+}
+
+func BenchmarkInternKeyword(b *testing.B) {
+	source := []byte("local.get i32.const i32.add call br_if f64.reinterpret_i64 module")
+	var keywords [][2]int
+	for p := 0; p < len(source); {
+		end := p
+		for end < len(source) && source[end] != ' ' {
+			end++
+		}
+		keywords = append(keywords, [2]int{p, end})
+		p = end + 1
+	}
+
+	b.Run("InternKeyword", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, k := range keywords {
+				InternKeyword(source, k[0], k[1])
+			}
+		}
+	})
+
+	ids := map[string]KeywordID{}
+	for op := 0; op < 256; op++ {
+		if keyword, ok := wasm.OptCodeName(KeywordID(op)); ok {
+			ids[keyword] = KeywordID(op)
+		}
+	}
+	b.Run("map", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, k := range keywords {
+				_ = ids[string(source[k[0]:k[1]])]
+			}
+		}
+	})
+}
@@ -0,0 +1,619 @@
+package text
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// ParseToken is called by LexRange and LexReader for each token, which allows a parser to inspect a token without
+// necessarily allocating strings
+// * source is the underlying byte stream: do not modify this
+// * tok is the classification of the token
+// * beginLine is the line number of the first byte of the token, starting at 1
+// * beginCol is the column number of the first byte of the token, starting at 1
+// * beginPos is the byte position in the source where the token begins, inclusive
+// * endPos is the byte position in the source where the token ends, exclusive
+//
+// Returning an error will short-circuit any future invocations.
+type ParseToken func(source []byte, tok TokenType, beginLine, beginCol, beginPos, endPos int) error
+
+// lex invokes the parser function for each token in the source, in order, followed by TokenEOF. This returns an error
+// if the source isn't lexically valid or the parser returns an error.
+//
+// Line and column numbers begin at 1. A line ends with an unescaped newline ('\n'). Per the spec, a carriage return
+// ('\r') is whitespace, so "\r\n" ends a line once: at the '\n'. A bare '\r' only advances the column, so a
+// source with old Mac style line endings is one line, where a line comment continues to the end of the source. A
+// leading UTF-8 byte order mark is skipped.
+//
+// Columns count characters, not bytes: a multi-byte UTF-8 character in a comment or string is one column wide.
+//
+// Tokens needn't be separated by whitespace when the next begins with a character that can't be in the first: a
+// paren, a string or a comment. For example, "1(" is a TokenUN then a TokenLParen. Any other character after a token
+// is an error.
+//
+// The options limit the input accepted, ex. the nesting of block comments. The zero value uses the defaults.
+//
+// See https://www.w3.org/TR/wasm-core-1/#lexical-format%E2%91%A0
+func lex(source []byte, opts LexOptions, parser ParseToken) error {
+	return LexRange(source, 0, len(source), 1, 1, opts, parser)
+}
+
+// LexRange is like lex, except it only reads source[start:end], beginning at the given line and column. Positions
+// passed to the parser are still offsets into the whole source, so a tool can re-lex part of a file it lexed before.
+//
+// The range is lexed as if it were the whole source. It is the caller's responsibility to choose a start and end
+// outside any string or block comment, as a token can't continue past end.
+func LexRange(source []byte, start, end, startLine, startCol int, opts LexOptions, parser ParseToken) error {
+	if start < 0 || start > end || end > len(source) {
+		return fmt.Errorf("invalid range [%d:%d] of source with length %d", start, end, len(source))
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	if start == 0 {
+		start = bomLength(source[:end])
+	}
+	l := Lexer{LexOptions: opts, source: source[:end], p: start, line: startLine, col: startCol}
+	for {
+		tok, err := l.Next()
+		if err != nil {
+			return err
+		}
+		if err = parser(l.source, tok.Type, tok.Line, tok.Col, tok.BeginPos, tok.EndPos); err != nil {
+			return err
+		}
+		if tok.Type == TokenEOF {
+			return nil
+		}
+	}
+}
+
+// LexReader is like lex, except it reads the source from r in chunks. The parser is invoked for each token once the
+// data after it arrives, so a token can straddle chunks, ex. a long string or block comment.
+//
+// Positions are byte offsets into the whole stream and the source passed to the parser is the stream read so far.
+// This means LexReader retains the whole stream, same as lex: it lets parsing begin before the stream is read, but
+// doesn't reduce memory usage.
+func LexReader(r io.Reader, opts LexOptions, parser ParseToken) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	var source []byte
+	var l *Lexer
+	chunk := make([]byte, 4096)
+	eof := false
+	retryAt := 0 // the length source must reach before lexing again, which amortizes re-scanning incomplete tokens
+	for {
+		if !eof {
+			n, err := r.Read(chunk)
+			source = append(source, chunk[:n]...)
+			if err == io.EOF {
+				eof = true
+			} else if err != nil {
+				return err
+			} else if len(source) < retryAt {
+				continue
+			}
+		}
+		if l == nil {
+			if !eof && len(source) < len(bom) {
+				continue // wait until a byte order mark could be detected
+			}
+			l = NewLexer(source)
+			l.LexOptions = opts
+		}
+
+		l.source = source
+		for {
+			saved := *l
+			tok, err := l.Next()
+			if !eof && (err != nil || tok.Type == TokenEOF || tok.EndPos == len(source)) {
+				// The token or error may be due to the end of data read so far, so retry once there's more.
+				*l = saved
+				retryAt = 2*len(source) - l.p
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if err = parser(source, tok.Type, tok.Line, tok.Col, tok.BeginPos, tok.EndPos); err != nil {
+				return err
+			}
+			if tok.Type == TokenEOF {
+				return nil
+			}
+		}
+	}
+}
+
+// LexError is returned when the source isn't lexically valid. Its Error is formatted as "line:col message".
+type LexError struct {
+	// Line is the line number of the error, starting at 1.
+	Line int
+	// Col is the column number of the error, starting at 1.
+	Col int
+	// Pos is the byte position in the source of the error.
+	Pos int
+	// Message describes the error, ex. "unexpected character ','".
+	Message string
+}
+
+func newLexError(line, col, pos int, format string, args ...interface{}) *LexError {
+	return &LexError{Line: line, Col: col, Pos: pos, Message: fmt.Sprintf(format, args...)}
+}
+
+// Error implements error.
+func (e *LexError) Error() string {
+	return fmt.Sprintf("%d:%d %s", e.Line, e.Col, e.Message)
+}
+
+// Token is a token returned by Lexer.Next. Its bytes are source[BeginPos:EndPos].
+type Token struct {
+	// Type is the classification of the token.
+	Type TokenType
+	// Line is the line number of the first byte of the token, starting at 1.
+	Line int
+	// Col is the column number of the first byte of the token, starting at 1.
+	Col int
+	// BeginPos is the byte position in the source where the token begins, inclusive.
+	BeginPos int
+	// EndPos is the byte position in the source where the token ends, exclusive.
+	EndPos int
+}
+
+// LexOptions limit the input accepted by LexRange, LexReader and a Lexer. The zero value uses the defaults.
+type LexOptions struct {
+	// MaxBlockCommentDepth is the maximum nesting of block comments, or DefaultMaxBlockCommentDepth when zero. This
+	// bounds the work spent on pathological input, such as a large number of "(;" without any ";)". A negative value
+	// is an error, so the limit can't be disabled.
+	MaxBlockCommentDepth int
+}
+
+// DefaultMaxBlockCommentDepth is the maximum nesting of block comments, unless overridden by
+// LexOptions.MaxBlockCommentDepth.
+const DefaultMaxBlockCommentDepth = 255
+
+func (o LexOptions) validate() error {
+	if o.MaxBlockCommentDepth < 0 {
+		return fmt.Errorf("invalid MaxBlockCommentDepth %d", o.MaxBlockCommentDepth)
+	}
+	return nil
+}
+
+// Lexer returns the tokens in a source one at a time, for parsers that pull tokens instead of accepting them in a
+// ParseToken callback. Positions follow the same rules as lex.
+type Lexer struct {
+	// LexOptions may be changed before the first call to Next.
+	LexOptions
+
+	source    []byte
+	p         int
+	line, col int
+}
+
+// NewLexer returns a Lexer positioned at the beginning of the source, after any UTF-8 byte order mark.
+func NewLexer(source []byte) *Lexer {
+	return &Lexer{source: source, p: bomLength(source), line: 1, col: 1}
+}
+
+// bom is the UTF-8 byte order mark, which some editors write at the beginning of a file.
+var bom = []byte{0xef, 0xbb, 0xbf}
+
+// bomLength returns the length of the byte order mark at the beginning of the source, or zero if there isn't one.
+//
+// The byte order mark isn't a token or whitespace, so it doesn't count as a column: the first character after it is
+// at column 1. Positions are still offsets into the source, so the first token begins at position 3.
+func bomLength(source []byte) int {
+	if bytes.HasPrefix(source, bom) {
+		return len(bom)
+	}
+	return 0
+}
+
+// Next returns the next token in the source, or a TokenEOF after the last. Calling Next after TokenEOF returns
+// TokenEOF again.
+//
+// This returns an error if the source isn't lexically valid. The Lexer must not be used after an error.
+func (l *Lexer) Next() (Token, error) {
+	source, length := l.source, len(l.source)
+	line, col := l.line, l.col
+	blockCommentLevel, maxBlockCommentDepth := 0, l.MaxBlockCommentDepth
+	if maxBlockCommentDepth == 0 {
+		maxBlockCommentDepth = DefaultMaxBlockCommentDepth
+	} else if err := l.validate(); err != nil {
+		return Token{}, err
+	}
+	var blockCommentLine, blockCommentCol, blockCommentPos int
+	for p := l.p; p < length; p++ {
+		b1 := source[p]
+		var b2 byte
+		if p+1 < length {
+			b2 = source[p+1]
+		}
+
+		// Block comments can nest and contain any characters, including newlines.
+		// See https://www.w3.org/TR/wasm-core-1/#comments%E2%91%A0
+		if blockCommentLevel > 0 {
+			switch {
+			case b1 == '(' && b2 == ';':
+				if blockCommentLevel == maxBlockCommentDepth {
+					return Token{}, newLexError(line, col, p, "block comment nested too deeply")
+				}
+				blockCommentLevel++
+				p++
+				col += 2
+			case b1 == ';' && b2 == ')':
+				blockCommentLevel--
+				p++
+				col += 2
+			case b1 == '\n':
+				line++
+				col = 1
+			case b1 < utf8.RuneSelf:
+				col++
+			default:
+				size, err := decodeRune(source, p, line, col)
+				if err != nil {
+					return Token{}, err
+				}
+				p += size - 1
+				col++
+			}
+			continue
+		}
+
+		switch b1 {
+		case ' ', '\t', '\r': // whitespace
+			col++
+			continue
+		case '\n':
+			line++
+			col = 1
+			continue
+		case ';':
+			if b2 != ';' {
+				return Token{}, newLexError(line, col, p, "unexpected character %q", b1)
+			}
+			// Line comments continue until the next newline or the end of the source.
+			p++
+			col += 2
+			for ; p+1 < length && source[p+1] != '\n'; p++ {
+				if source[p+1] >= utf8.RuneSelf {
+					size, err := decodeRune(source, p+1, line, col)
+					if err != nil {
+						return Token{}, err
+					}
+					p += size - 1
+				}
+				col++
+			}
+			continue
+		case '(':
+			if b2 == ';' {
+				blockCommentLevel = 1
+				blockCommentLine, blockCommentCol, blockCommentPos = line, col, p
+				p++
+				col += 2
+				continue
+			}
+		}
+
+		var tok TokenType
+		switch tokenStartMap[b1] {
+		case tokenStartLParen:
+			return l.emit(TokenLParen, line, col, p, p+1, col+1), nil
+		case tokenStartRParen:
+			return l.emit(TokenRParen, line, col, p, p+1, col+1), nil
+		case tokenStartQuote:
+			// Strings can include any UTF-8 character, but not a raw control character such as newline. Escapes are
+			// only scanned here, not decoded, as the parser decides what the bytes mean.
+			// See https://www.w3.org/TR/wasm-core-1/#strings%E2%91%A0
+			end, endCol := p+1, col+1
+			for ; end < length && source[end] != '"'; end++ {
+				c := source[end]
+				switch {
+				case c == '\\' && end+1 < length && (source[end+1] == '"' || source[end+1] == '\\'):
+					end++ // skip the escaped character, so that it can't end the string
+					endCol++
+				case c < ' ' || c == 0x7f:
+					return Token{}, newLexError(line, endCol, end, "unexpected character %q", c)
+				case c >= utf8.RuneSelf:
+					size, err := decodeRune(source, end, line, endCol)
+					if err != nil {
+						return Token{}, err
+					}
+					end += size - 1
+				}
+				endCol++
+			}
+			if end == length {
+				return Token{}, newLexError(line, col, p, "expected string end '\"'")
+			}
+			return l.emit(TokenString, line, col, p, end+1, endCol+1), nil // include the closing quote
+		case tokenStartLetter:
+			tok = TokenKeyword
+		case tokenStartDigit:
+			// The numeric value isn't validated here as that depends on the context, ex. i32 vs i64.
+			tok = TokenUN
+		case tokenStartSign:
+			// A sign is only part of a number when a digit immediately follows it. Otherwise, ex. "+" or "+foo", it
+			// begins a reserved token unless it is a signed "inf" or "nan".
+			if isDecimalDigit(b2) {
+				tok = TokenSN
+			} else {
+				tok = TokenReserved
+			}
+		case tokenStartDollar:
+			// A bare "$" is reserved, as an identifier needs at least one idchar after the "$".
+			if asciiMap[b2] == asciiTypeIDChar {
+				tok = TokenID
+			} else {
+				tok = TokenReserved
+			}
+		case tokenStartIDChar:
+			tok = TokenReserved
+		default:
+			if b1 < utf8.RuneSelf {
+				return Token{}, newLexError(line, col, p, "unexpected character %q", b1)
+			}
+			r, _ := utf8.DecodeRune(source[p:])
+			return Token{}, newLexError(line, col, p, "unexpected character %q", r)
+		}
+
+		// Tokens are a run of idchar, which have the same width in bytes and columns.
+		end := p + 1
+		for end < length && asciiMap[source[end]] == asciiTypeIDChar {
+			end++
+		}
+		// A character that can't begin a token also can't follow one, but report where the token began, as the
+		// character may have been intended as part of it, ex. "abc,def".
+		if end < length && !endsToken(source, end) {
+			endCol := col + end - p
+			r, _ := utf8.DecodeRune(source[end:])
+			return Token{}, newLexError(line, endCol, end, "unexpected character %q in token starting at %d:%d", r, line, col)
+		}
+		switch tok {
+		case TokenUN, TokenSN:
+			tok = numberType(tok, source[p:end])
+		case TokenKeyword:
+			if isInfOrNaN(source[p:end]) {
+				tok = TokenFN
+			}
+		case TokenReserved:
+			if (b1 == '+' || b1 == '-') && isInfOrNaN(source[p+1:end]) {
+				tok = TokenFN
+			}
+		}
+		if tok == TokenUN || tok == TokenSN || tok == TokenFN {
+			if i := invalidUnderscore(source[p:end]); i != -1 {
+				return Token{}, newLexError(line, col+i, p+i, "invalid underscore placement in number")
+			}
+		}
+		return l.emit(tok, line, col, p, end, col+end-p), nil
+	}
+
+	if blockCommentLevel > 0 {
+		return Token{}, newLexError(blockCommentLine, blockCommentCol, blockCommentPos, "expected block comment end ';)'")
+	}
+	return l.emit(TokenEOF, line, col, length, length, col), nil
+}
+
+// endsToken returns true if source[p] can follow a token: whitespace, a comment or the beginning of another token.
+func endsToken(source []byte, p int) bool {
+	switch b := source[p]; {
+	case b == ';':
+		return p+1 < len(source) && source[p+1] == ';'
+	case asciiMap[b] == asciiTypeWhitespace:
+		return true
+	}
+	return tokenStartMap[source[p]] != tokenStartInvalid
+}
+
+// emit returns a token that begins at the given line and column, and resumes lexing after it. Tokens don't include
+// newlines, so the next token begins on the same line, at endCol.
+func (l *Lexer) emit(tok TokenType, line, col, beginPos, endPos, endCol int) Token {
+	l.p, l.line, l.col = endPos, line, endCol
+	return Token{Type: tok, Line: line, Col: col, BeginPos: beginPos, EndPos: endPos}
+}
+
+// numberType returns TokenFN if the number, which begins with an optional sign and a digit, has a fraction or an
+// exponent, or the given integer type if not. This returns TokenReserved if the number is malformed, ex. "1abc" or
+// "0x", so that the parser can report it in context. Underscores are treated as digits here: see invalidUnderscore.
+//
+// The exponent begins with 'e' or 'E' in a decimal number, but 'p' or 'P' in a hexadecimal one, as 'e' and 'E' are
+// hexadecimal digits.
+//
+// See https://www.w3.org/TR/wasm-core-1/#floating-point%E2%91%A6
+func numberType(tok TokenType, number []byte) TokenType {
+	i := 0
+	if number[0] == '+' || number[0] == '-' {
+		i++
+	}
+	isDigit, exponent := isDecimalDigit, byte('e')
+	if len(number) > i+1 && number[i] == '0' && number[i+1] == 'x' {
+		i += 2
+		isDigit, exponent = isHexDigit, 'p'
+	}
+
+	var digits int
+	if i, digits = skipDigits(number, i, isDigit); digits == 0 {
+		return TokenReserved
+	}
+	if i < len(number) && number[i] == '.' {
+		tok = TokenFN
+		i, _ = skipDigits(number, i+1, isDigit)
+	}
+	if i < len(number) && (number[i] == exponent || number[i] == exponent-'a'+'A') {
+		tok = TokenFN
+		i++
+		if i < len(number) && (number[i] == '+' || number[i] == '-') {
+			i++
+		}
+		if i, digits = skipDigits(number, i, isDecimalDigit); digits == 0 {
+			return TokenReserved
+		}
+	}
+	if i != len(number) {
+		return TokenReserved
+	}
+	return tok
+}
+
+// skipDigits returns the index after the digits and underscores that begin at number[i] and how many there were.
+func skipDigits(number []byte, i int, isDigit func(byte) bool) (int, int) {
+	start := i
+	for i < len(number) && (number[i] == '_' || isDigit(number[i])) {
+		i++
+	}
+	return i, i - start
+}
+
+var (
+	inf       = []byte("inf")
+	nan       = []byte("nan")
+	nanPrefix = []byte("nan:0x")
+)
+
+// isInfOrNaN returns true if the unsigned token is "inf", "nan" or a NaN with a hexadecimal payload, ex. "nan:0xfff".
+//
+// See https://www.w3.org/TR/wasm-core-1/#floating-point%E2%91%A6
+func isInfOrNaN(token []byte) bool {
+	if bytes.Equal(token, inf) || bytes.Equal(token, nan) {
+		return true
+	}
+	if !bytes.HasPrefix(token, nanPrefix) {
+		return false
+	}
+	i, digits := skipDigits(token, len(nanPrefix), isHexDigit)
+	return digits > 0 && i == len(token)
+}
+
+// invalidUnderscore returns the index of the first underscore in the number that isn't between two digits, or -1 if
+// there isn't one. Digits are hexadecimal when the number has a "0x" prefix, including the payload of a NaN.
+//
+// Note: A number can't begin with an underscore, as "_100" lexes as a reserved token.
+//
+// See https://www.w3.org/TR/wasm-core-1/#integers%E2%91%A6
+func invalidUnderscore(number []byte) int {
+	i := 0
+	if number[0] == '+' || number[0] == '-' {
+		i++
+	}
+	if bytes.HasPrefix(number[i:], nanPrefix) {
+		i += len("nan:")
+	}
+	isDigit := isDecimalDigit
+	if len(number) > i+1 && number[i] == '0' && number[i+1] == 'x' {
+		i += 2
+		isDigit = isHexDigit
+	}
+	for start := i; i < len(number); i++ {
+		if number[i] != '_' {
+			continue
+		}
+		if i == start || !isDigit(number[i-1]) || i+1 == len(number) || !isDigit(number[i+1]) {
+			return i
+		}
+	}
+	return -1
+}
+
+func isDecimalDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+func isHexDigit(b byte) bool {
+	return isDecimalDigit(b) || (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F')
+}
+
+// decodeRune returns the size of the UTF-8 character at source[p] or an error if it is invalid.
+func decodeRune(source []byte, p, line, col int) (int, error) {
+	r, size := utf8.DecodeRune(source[p:])
+	if r == utf8.RuneError && size == 1 {
+		return 0, newLexError(line, col, p, "found an invalid byte in UTF-8 sequence: %#x", source[p])
+	}
+	return size, nil
+}
+
+type asciiType byte
+
+const (
+	// asciiTypeNone means the character is only valid in a string or comment.
+	asciiTypeNone asciiType = iota
+	// asciiTypeIDChar means the character is an idchar, which make up keywords, numbers, identifiers and reserved
+	// tokens.
+	//
+	// See https://www.w3.org/TR/wasm-core-1/#text-idchar
+	asciiTypeIDChar
+	// asciiTypeWhitespace means the character separates tokens, ex. a space or newline. Comments also separate tokens,
+	// but begin with more than one character.
+	//
+	// See https://www.w3.org/TR/wasm-core-1/#white-space%E2%91%A0
+	asciiTypeWhitespace
+)
+
+// asciiMap classifies each byte, so that the lexer can categorize characters without branching. Bytes not in the
+// ASCII range are asciiTypeNone.
+var asciiMap = func() (ret [256]asciiType) {
+	for _, b := range []byte("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz!#$%&'*+-./:<=>?@\\^_`|~") {
+		ret[b] = asciiTypeIDChar
+	}
+	for _, b := range []byte(" \t\n\r") {
+		ret[b] = asciiTypeWhitespace
+	}
+	return
+}()
+
+// IsIDChar returns true if the byte is an idchar, which make up keywords, numbers, identifiers and reserved tokens.
+//
+// See https://www.w3.org/TR/wasm-core-1/#text-idchar
+func IsIDChar(b byte) bool {
+	return asciiMap[b] == asciiTypeIDChar
+}
+
+// IsWhitespace returns true if the byte is a space, tab, newline or carriage return.
+//
+// See https://www.w3.org/TR/wasm-core-1/#white-space%E2%91%A0
+func IsWhitespace(b byte) bool {
+	return asciiMap[b] == asciiTypeWhitespace
+}
+
+// tokenStart classifies the first byte of a token, which decides its type before the rest of it is read.
+type tokenStart byte
+
+const (
+	// tokenStartInvalid means the byte can't begin a token, ex. a control character or a byte of non-ASCII UTF-8.
+	tokenStartInvalid tokenStart = iota
+	tokenStartLParen
+	tokenStartRParen
+	tokenStartQuote
+	// tokenStartLetter is a lowercase letter, which begins a keyword.
+	tokenStartLetter
+	tokenStartDigit
+	// tokenStartSign is '+' or '-', which begins a number when a digit follows it.
+	tokenStartSign
+	// tokenStartDollar begins an identifier when an idchar follows it.
+	tokenStartDollar
+	// tokenStartIDChar is any other idchar, which begins a reserved token.
+	tokenStartIDChar
+)
+
+// tokenStartMap classifies the first byte of each token, so that the lexer can dispatch on it with one switch.
+var tokenStartMap = func() (ret [256]tokenStart) {
+	for b, t := range asciiMap {
+		if t == asciiTypeIDChar {
+			ret[b] = tokenStartIDChar
+		}
+	}
+	for b := 'a'; b <= 'z'; b++ {
+		ret[b] = tokenStartLetter
+	}
+	for b := '0'; b <= '9'; b++ {
+		ret[b] = tokenStartDigit
+	}
+	ret['('], ret[')'], ret['"'] = tokenStartLParen, tokenStartRParen, tokenStartQuote
+	ret['+'], ret['-'], ret['$'] = tokenStartSign, tokenStartSign, tokenStartDollar
+	return
+}()
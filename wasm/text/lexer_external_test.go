@@ -0,0 +1,47 @@
+package text_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/wazero/wasm/text"
+)
+
+// These tests are in a separate package to ensure the lexer can be used outside it.
+
+func TestLexRange_External(t *testing.T) {
+	source := []byte("(module $m)")
+	var actual []text.TokenType
+	err := text.LexRange(source, 1, len(source), 1, 2, text.LexOptions{}, func(source []byte, tok text.TokenType, beginLine, beginCol, beginPos, endPos int) error {
+		actual = append(actual, tok)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []text.TokenType{text.TokenKeyword, text.TokenID, text.TokenRParen, text.TokenEOF}, actual)
+}
+
+func TestLexReader_External(t *testing.T) {
+	var actual []text.TokenType
+	err := text.LexReader(strings.NewReader("(module $m)"), text.LexOptions{}, func(source []byte, tok text.TokenType, beginLine, beginCol, beginPos, endPos int) error {
+		actual = append(actual, tok)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []text.TokenType{text.TokenLParen, text.TokenKeyword, text.TokenID, text.TokenRParen, text.TokenEOF}, actual)
+}
+
+func TestLexer_Next_External(t *testing.T) {
+	l := text.NewLexer([]byte("(module)"))
+	var actual []text.TokenType
+	for {
+		tok, err := l.Next()
+		require.NoError(t, err)
+		actual = append(actual, tok.Type)
+		if tok.Type == text.TokenEOF {
+			break
+		}
+	}
+	require.Equal(t, []text.TokenType{text.TokenLParen, text.TokenKeyword, text.TokenRParen, text.TokenEOF}, actual)
+}
@@ -0,0 +1,1052 @@
+package text
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+// exampleWat stores the 10th Fibonacci number at offset zero of its memory.
+var exampleWat = []byte(`(module
+  ;; stores the 10th Fibonacci number at offset zero
+  (memory 1)
+  (func $main (local i32 i32 i32)
+    (set_local 0 (i32.const 0))
+    (set_local 1 (i32.const 1))
+    (set_local 2 (i32.const 10))
+    (loop
+      (set_local 1 (i32.add (get_local 0) (tee_local 0 (get_local 1))))
+      (br_if 0 (tee_local 2 (i32.sub (get_local 2) (i32.const 1))))
+    )
+    (i32.store (i32.const 0) (get_local 0))
+  )
+  (start $main)
+)
+`)
+
+func TestLex(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []*token
+	}{
+		{name: "empty"},
+		{name: "only whitespace", input: " \t\r\n"},
+		{name: "only line comment", input: ";; hello"},
+		{name: "line comment with parens", input: ";; ( )"},
+		{name: "only block comment", input: "(; hello ;)"},
+		{name: "only nested block comment", input: "(; (; hello ;) ;)"},
+		{
+			name:     "parens",
+			input:    "()",
+			expected: []*token{{TokenLParen, 1, 1, 0, "("}, {TokenRParen, 1, 2, 1, ")"}},
+		},
+		{
+			name:     "shortest keywords",
+			input:    "a z",
+			expected: []*token{{TokenKeyword, 1, 1, 0, "a"}, {TokenKeyword, 1, 3, 2, "z"}},
+		},
+		{
+			name:     "keyword with idchars",
+			input:    "i32.const",
+			expected: []*token{{TokenKeyword, 1, 1, 0, "i32.const"}},
+		},
+		{
+			name:  "module empty",
+			input: "(module)",
+			expected: []*token{
+				{TokenLParen, 1, 1, 0, "("},
+				{TokenKeyword, 1, 2, 1, "module"},
+				{TokenRParen, 1, 8, 7, ")"},
+			},
+		},
+		{
+			name:  "module empty after line comment",
+			input: ";; comment\n(module)",
+			expected: []*token{
+				{TokenLParen, 2, 1, 11, "("},
+				{TokenKeyword, 2, 2, 12, "module"},
+				{TokenRParen, 2, 8, 18, ")"},
+			},
+		},
+		{
+			name:  "module empty after block comment",
+			input: "(; comment ;)(module)",
+			expected: []*token{
+				{TokenLParen, 1, 14, 13, "("},
+				{TokenKeyword, 1, 15, 14, "module"},
+				{TokenRParen, 1, 21, 20, ")"},
+			},
+		},
+		{
+			name:  "module empty after multi-line block comment",
+			input: "(; one\ntwo ;)\n(module)",
+			expected: []*token{
+				{TokenLParen, 3, 1, 14, "("},
+				{TokenKeyword, 3, 2, 15, "module"},
+				{TokenRParen, 3, 8, 21, ")"},
+			},
+		},
+		{
+			name:  "module empty with CRLF line endings",
+			input: ";; comment\r\n(module\r\n)",
+			expected: []*token{
+				{TokenLParen, 2, 1, 12, "("},
+				{TokenKeyword, 2, 2, 13, "module"},
+				{TokenRParen, 3, 1, 21, ")"},
+			},
+		},
+		{
+			name:  "module empty with CR line endings",
+			input: "(module\r(memory 1)\r)\r",
+			expected: []*token{
+				{TokenLParen, 1, 1, 0, "("},
+				{TokenKeyword, 1, 2, 1, "module"},
+				{TokenLParen, 1, 9, 8, "("},
+				{TokenKeyword, 1, 10, 9, "memory"},
+				{TokenUN, 1, 17, 16, "1"},
+				{TokenRParen, 1, 18, 17, ")"},
+				{TokenRParen, 1, 20, 19, ")"},
+			},
+		},
+		{
+			name:     "CR in block comment",
+			input:    "(; \r\r\n\r ;)(",
+			expected: []*token{{TokenLParen, 2, 5, 10, "("}},
+		},
+		{
+			name:     "CR doesn't end a line comment",
+			input:    ";; comment\r(module)\n(",
+			expected: []*token{{TokenLParen, 2, 1, 20, "("}},
+		},
+		{
+			name:     "unicode in line comment",
+			input:    ";; ☺\n(",
+			expected: []*token{{TokenLParen, 2, 1, 7, "("}},
+		},
+		{
+			name:     "unicode in block comment",
+			input:    "(; ☺ ;)(",
+			expected: []*token{{TokenLParen, 1, 8, 9, "("}},
+		},
+		{
+			name:     "unicode of each width in block comment",
+			input:    "(; é☺😀 ;)(",
+			expected: []*token{{TokenLParen, 1, 10, 15, "("}},
+		},
+		{
+			name:     "unicode in multi-line block comment",
+			input:    "(;\n☺😀;)(",
+			expected: []*token{{TokenLParen, 2, 5, 12, "("}},
+		},
+		{
+			name:     "unicode of each width in line comment",
+			input:    ";;é☺😀 (\n(",
+			expected: []*token{{TokenLParen, 2, 1, 14, "("}},
+		},
+		{
+			name:     "id",
+			input:    "$main",
+			expected: []*token{{TokenID, 1, 1, 0, "$main"}},
+		},
+		{
+			name:     "id with idchars",
+			input:    "$foo.bar",
+			expected: []*token{{TokenID, 1, 1, 0, "$foo.bar"}},
+		},
+		{
+			name:     "dollar alone is reserved",
+			input:    "$",
+			expected: []*token{{TokenReserved, 1, 1, 0, "$"}},
+		},
+		{
+			name:     "dollar before a paren is reserved",
+			input:    "($)",
+			expected: []*token{{TokenLParen, 1, 1, 0, "("}, {TokenReserved, 1, 2, 1, "$"}, {TokenRParen, 1, 3, 2, ")"}},
+		},
+		{
+			name:     "integer",
+			input:    "10",
+			expected: []*token{{TokenUN, 1, 1, 0, "10"}},
+		},
+		{
+			name:     "integer with underscore",
+			input:    "1_0",
+			expected: []*token{{TokenUN, 1, 1, 0, "1_0"}},
+		},
+		{
+			name:     "hex integer",
+			input:    "0x0a",
+			expected: []*token{{TokenUN, 1, 1, 0, "0x0a"}},
+		},
+		{
+			name:     "hex integer with underscore",
+			input:    "0x0_A",
+			expected: []*token{{TokenUN, 1, 1, 0, "0x0_A"}},
+		},
+		{
+			name:     "integer with many underscores",
+			input:    "1_000_000_000_000",
+			expected: []*token{{TokenUN, 1, 1, 0, "1_000_000_000_000"}},
+		},
+		{
+			name:     "leading underscore is reserved",
+			input:    "_100",
+			expected: []*token{{TokenReserved, 1, 1, 0, "_100"}},
+		},
+		{
+			name:     "signed integer",
+			input:    "+10",
+			expected: []*token{{TokenSN, 1, 1, 0, "+10"}},
+		},
+		{
+			name:     "signed integer with underscore",
+			input:    "+1_0",
+			expected: []*token{{TokenSN, 1, 1, 0, "+1_0"}},
+		},
+		{
+			name:     "negative hex integer",
+			input:    "-0x1F",
+			expected: []*token{{TokenSN, 1, 1, 0, "-0x1F"}},
+		},
+		{
+			name:     "number followed by a dollar is reserved",
+			input:    "0$y",
+			expected: []*token{{TokenReserved, 1, 1, 0, "0$y"}},
+		},
+		{
+			name:     "number followed by letters is reserved",
+			input:    "1abc",
+			expected: []*token{{TokenReserved, 1, 1, 0, "1abc"}},
+		},
+		{
+			name:     "sign followed by a dollar is reserved",
+			input:    "+$x",
+			expected: []*token{{TokenReserved, 1, 1, 0, "+$x"}},
+		},
+		{
+			name:     "hex prefix alone is reserved",
+			input:    "0x -0x",
+			expected: []*token{{TokenReserved, 1, 1, 0, "0x"}, {TokenReserved, 1, 4, 3, "-0x"}},
+		},
+		{
+			name:     "exponent without digits is reserved",
+			input:    "1e 0x1p+",
+			expected: []*token{{TokenReserved, 1, 1, 0, "1e"}, {TokenReserved, 1, 4, 3, "0x1p+"}},
+		},
+		{
+			name:     "float with two fractions is reserved",
+			input:    "1.2.3",
+			expected: []*token{{TokenReserved, 1, 1, 0, "1.2.3"}},
+		},
+		{
+			name:     "nan with a non-hex payload is a keyword",
+			input:    "nan:0xg",
+			expected: []*token{{TokenKeyword, 1, 1, 0, "nan:0xg"}},
+		},
+		{
+			name:     "sign alone is reserved",
+			input:    "-",
+			expected: []*token{{TokenReserved, 1, 1, 0, "-"}},
+		},
+		{
+			name:     "sign before letters is reserved",
+			input:    "+foo",
+			expected: []*token{{TokenReserved, 1, 1, 0, "+foo"}},
+		},
+		{
+			name:     "float with fraction",
+			input:    "1.5",
+			expected: []*token{{TokenFN, 1, 1, 0, "1.5"}},
+		},
+		{
+			name:     "float with empty fraction and exponent",
+			input:    "1.e10",
+			expected: []*token{{TokenFN, 1, 1, 0, "1.e10"}},
+		},
+		{
+			name:     "float with exponent",
+			input:    "-1E+1_0",
+			expected: []*token{{TokenFN, 1, 1, 0, "-1E+1_0"}},
+		},
+		{
+			name:     "hex float",
+			input:    "0x1.fff_fffp+1_023",
+			expected: []*token{{TokenFN, 1, 1, 0, "0x1.fff_fffp+1_023"}},
+		},
+		{
+			name:     "hex float with exponent",
+			input:    "-0x1P-2",
+			expected: []*token{{TokenFN, 1, 1, 0, "-0x1P-2"}},
+		},
+		{
+			name:     "hex e is a digit",
+			input:    "0x1e",
+			expected: []*token{{TokenUN, 1, 1, 0, "0x1e"}},
+		},
+		{
+			name:     "inf",
+			input:    "inf",
+			expected: []*token{{TokenFN, 1, 1, 0, "inf"}},
+		},
+		{
+			name:     "signed inf",
+			input:    "+inf -inf",
+			expected: []*token{{TokenFN, 1, 1, 0, "+inf"}, {TokenFN, 1, 6, 5, "-inf"}},
+		},
+		{
+			name:     "nan",
+			input:    "nan",
+			expected: []*token{{TokenFN, 1, 1, 0, "nan"}},
+		},
+		{
+			name:     "signed nan",
+			input:    "+nan",
+			expected: []*token{{TokenFN, 1, 1, 0, "+nan"}},
+		},
+		{
+			name:     "nan with payload",
+			input:    "nan:0xfff",
+			expected: []*token{{TokenFN, 1, 1, 0, "nan:0xfff"}},
+		},
+		{
+			name:     "signed nan with payload",
+			input:    "-nan:0xfffffffffffff",
+			expected: []*token{{TokenFN, 1, 1, 0, "-nan:0xfffffffffffff"}},
+		},
+		{
+			name:     "keywords that begin like inf or nan",
+			input:    "info nan:",
+			expected: []*token{{TokenKeyword, 1, 1, 0, "info"}, {TokenKeyword, 1, 6, 5, "nan:"}},
+		},
+		{
+			name:  "f64.const",
+			input: "(f64.const -nan:0x1)",
+			expected: []*token{
+				{TokenLParen, 1, 1, 0, "("},
+				{TokenKeyword, 1, 2, 1, "f64.const"},
+				{TokenFN, 1, 12, 11, "-nan:0x1"},
+				{TokenRParen, 1, 20, 19, ")"},
+			},
+		},
+		{
+			name:     "empty string",
+			input:    `""`,
+			expected: []*token{{TokenString, 1, 1, 0, `""`}},
+		},
+		{
+			name:     "string with escaped newline",
+			input:    `("\n")`,
+			expected: []*token{{TokenLParen, 1, 1, 0, "("}, {TokenString, 1, 2, 1, "\"\\n\""}, {TokenRParen, 1, 6, 5, ")"}},
+		},
+		{
+			name:     "string with escaped quote",
+			input:    `"\"" a`,
+			expected: []*token{{TokenString, 1, 1, 0, `"\""`}, {TokenKeyword, 1, 6, 5, "a"}},
+		},
+		{
+			name:     "string with escaped backslash",
+			input:    `"\\" a`,
+			expected: []*token{{TokenString, 1, 1, 0, `"\\"`}, {TokenKeyword, 1, 6, 5, "a"}},
+		},
+		{
+			name:     "string with unicode",
+			input:    `"☺" a`,
+			expected: []*token{{TokenString, 1, 1, 0, `"☺"`}, {TokenKeyword, 1, 5, 6, "a"}},
+		},
+		{
+			name:     "string with parens and semicolons",
+			input:    `"(; ;; )"`,
+			expected: []*token{{TokenString, 1, 1, 0, `"(; ;; )"`}},
+		},
+		{
+			name:  "data segment",
+			input: `(data (i32.const 0) "hello")`,
+			expected: []*token{
+				{TokenLParen, 1, 1, 0, "("},
+				{TokenKeyword, 1, 2, 1, "data"},
+				{TokenLParen, 1, 7, 6, "("},
+				{TokenKeyword, 1, 8, 7, "i32.const"},
+				{TokenUN, 1, 18, 17, "0"},
+				{TokenRParen, 1, 19, 18, ")"},
+				{TokenString, 1, 21, 20, `"hello"`},
+				{TokenRParen, 1, 28, 27, ")"},
+			},
+		},
+		{
+			name:  "i32.const",
+			input: "(i32.const 10)",
+			expected: []*token{
+				{TokenLParen, 1, 1, 0, "("},
+				{TokenKeyword, 1, 2, 1, "i32.const"},
+				{TokenUN, 1, 12, 11, "10"},
+				{TokenRParen, 1, 14, 13, ")"},
+			},
+		},
+		{
+			name:  "paren after s-expression",
+			input: "(i32.const 1)(",
+			expected: []*token{
+				{TokenLParen, 1, 1, 0, "("},
+				{TokenKeyword, 1, 2, 1, "i32.const"},
+				{TokenUN, 1, 12, 11, "1"},
+				{TokenRParen, 1, 13, 12, ")"},
+				{TokenLParen, 1, 14, 13, "("},
+			},
+		},
+		{
+			name:     "number then paren",
+			input:    "1(",
+			expected: []*token{{TokenUN, 1, 1, 0, "1"}, {TokenLParen, 1, 2, 1, "("}},
+		},
+		{
+			name:     "float then paren",
+			input:    "-1.5e3)",
+			expected: []*token{{TokenFN, 1, 1, 0, "-1.5e3"}, {TokenRParen, 1, 7, 6, ")"}},
+		},
+		{
+			name:     "id then paren",
+			input:    "$main(",
+			expected: []*token{{TokenID, 1, 1, 0, "$main"}, {TokenLParen, 1, 6, 5, "("}},
+		},
+		{
+			name:     "keyword then string",
+			input:    `data"a""b"`,
+			expected: []*token{{TokenKeyword, 1, 1, 0, "data"}, {TokenString, 1, 5, 4, `"a"`}, {TokenString, 1, 8, 7, `"b"`}},
+		},
+		{
+			name:     "number then comments",
+			input:    "1(;c;)2;;c",
+			expected: []*token{{TokenUN, 1, 1, 0, "1"}, {TokenUN, 1, 7, 6, "2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, lexTokens(t, tc.input))
+		})
+	}
+}
+
+func TestLex_Example(t *testing.T) {
+	require.Equal(t, []*token{
+		{TokenLParen, 1, 1, 0, "("},
+		{TokenKeyword, 1, 2, 1, "module"},
+		{TokenLParen, 3, 3, 63, "("},
+		{TokenKeyword, 3, 4, 64, "memory"},
+		{TokenUN, 3, 11, 71, "1"},
+		{TokenRParen, 3, 12, 72, ")"},
+		{TokenLParen, 4, 3, 76, "("},
+		{TokenKeyword, 4, 4, 77, "func"},
+		{TokenID, 4, 9, 82, "$main"},
+		{TokenLParen, 4, 15, 88, "("},
+		{TokenKeyword, 4, 16, 89, "local"},
+		{TokenKeyword, 4, 22, 95, "i32"},
+		{TokenKeyword, 4, 26, 99, "i32"},
+		{TokenKeyword, 4, 30, 103, "i32"},
+		{TokenRParen, 4, 33, 106, ")"},
+		{TokenLParen, 5, 5, 112, "("},
+		{TokenKeyword, 5, 6, 113, "set_local"},
+		{TokenUN, 5, 16, 123, "0"},
+		{TokenLParen, 5, 18, 125, "("},
+		{TokenKeyword, 5, 19, 126, "i32.const"},
+		{TokenUN, 5, 29, 136, "0"},
+		{TokenRParen, 5, 30, 137, ")"},
+		{TokenRParen, 5, 31, 138, ")"},
+		{TokenLParen, 6, 5, 144, "("},
+		{TokenKeyword, 6, 6, 145, "set_local"},
+		{TokenUN, 6, 16, 155, "1"},
+		{TokenLParen, 6, 18, 157, "("},
+		{TokenKeyword, 6, 19, 158, "i32.const"},
+		{TokenUN, 6, 29, 168, "1"},
+		{TokenRParen, 6, 30, 169, ")"},
+		{TokenRParen, 6, 31, 170, ")"},
+		{TokenLParen, 7, 5, 176, "("},
+		{TokenKeyword, 7, 6, 177, "set_local"},
+		{TokenUN, 7, 16, 187, "2"},
+		{TokenLParen, 7, 18, 189, "("},
+		{TokenKeyword, 7, 19, 190, "i32.const"},
+		{TokenUN, 7, 29, 200, "10"},
+		{TokenRParen, 7, 31, 202, ")"},
+		{TokenRParen, 7, 32, 203, ")"},
+		{TokenLParen, 8, 5, 209, "("},
+		{TokenKeyword, 8, 6, 210, "loop"},
+		{TokenLParen, 9, 7, 221, "("},
+		{TokenKeyword, 9, 8, 222, "set_local"},
+		{TokenUN, 9, 18, 232, "1"},
+		{TokenLParen, 9, 20, 234, "("},
+		{TokenKeyword, 9, 21, 235, "i32.add"},
+		{TokenLParen, 9, 29, 243, "("},
+		{TokenKeyword, 9, 30, 244, "get_local"},
+		{TokenUN, 9, 40, 254, "0"},
+		{TokenRParen, 9, 41, 255, ")"},
+		{TokenLParen, 9, 43, 257, "("},
+		{TokenKeyword, 9, 44, 258, "tee_local"},
+		{TokenUN, 9, 54, 268, "0"},
+		{TokenLParen, 9, 56, 270, "("},
+		{TokenKeyword, 9, 57, 271, "get_local"},
+		{TokenUN, 9, 67, 281, "1"},
+		{TokenRParen, 9, 68, 282, ")"},
+		{TokenRParen, 9, 69, 283, ")"},
+		{TokenRParen, 9, 70, 284, ")"},
+		{TokenRParen, 9, 71, 285, ")"},
+		{TokenLParen, 10, 7, 293, "("},
+		{TokenKeyword, 10, 8, 294, "br_if"},
+		{TokenUN, 10, 14, 300, "0"},
+		{TokenLParen, 10, 16, 302, "("},
+		{TokenKeyword, 10, 17, 303, "tee_local"},
+		{TokenUN, 10, 27, 313, "2"},
+		{TokenLParen, 10, 29, 315, "("},
+		{TokenKeyword, 10, 30, 316, "i32.sub"},
+		{TokenLParen, 10, 38, 324, "("},
+		{TokenKeyword, 10, 39, 325, "get_local"},
+		{TokenUN, 10, 49, 335, "2"},
+		{TokenRParen, 10, 50, 336, ")"},
+		{TokenLParen, 10, 52, 338, "("},
+		{TokenKeyword, 10, 53, 339, "i32.const"},
+		{TokenUN, 10, 63, 349, "1"},
+		{TokenRParen, 10, 64, 350, ")"},
+		{TokenRParen, 10, 65, 351, ")"},
+		{TokenRParen, 10, 66, 352, ")"},
+		{TokenRParen, 10, 67, 353, ")"},
+		{TokenRParen, 11, 5, 359, ")"},
+		{TokenLParen, 12, 5, 365, "("},
+		{TokenKeyword, 12, 6, 366, "i32.store"},
+		{TokenLParen, 12, 16, 376, "("},
+		{TokenKeyword, 12, 17, 377, "i32.const"},
+		{TokenUN, 12, 27, 387, "0"},
+		{TokenRParen, 12, 28, 388, ")"},
+		{TokenLParen, 12, 30, 390, "("},
+		{TokenKeyword, 12, 31, 391, "get_local"},
+		{TokenUN, 12, 41, 401, "0"},
+		{TokenRParen, 12, 42, 402, ")"},
+		{TokenRParen, 12, 43, 403, ")"},
+		{TokenRParen, 13, 3, 407, ")"},
+		{TokenLParen, 14, 3, 411, "("},
+		{TokenKeyword, 14, 4, 412, "start"},
+		{TokenID, 14, 10, 418, "$main"},
+		{TokenRParen, 14, 15, 423, ")"},
+		{TokenRParen, 15, 1, 425, ")"},
+	}, lexTokens(t, string(exampleWat)))
+}
+
+func TestLex_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       []byte
+		expectedErr string
+	}{
+		{
+			name:        "unbalanced block comment",
+			input:       []byte("(; (; ;)"),
+			expectedErr: "1:1 expected block comment end ';)'",
+		},
+		{
+			name:        "unbalanced block comment on a later line",
+			input:       []byte("(module\n  (; ;)(;\n"),
+			expectedErr: "2:8 expected block comment end ';)'",
+		},
+		{
+			name:        "single semicolon",
+			input:       []byte("(module ;)"),
+			expectedErr: "1:9 unexpected character ';'",
+		},
+		{
+			name:        "unexpected ASCII",
+			input:       []byte("(module ,)"),
+			expectedErr: "1:9 unexpected character ','",
+		},
+		{
+			name:        "unexpected ASCII in token",
+			input:       []byte("abc,def"),
+			expectedErr: "1:4 unexpected character ',' in token starting at 1:1",
+		},
+		{
+			name:        "unexpected ASCII after token",
+			input:       []byte("(module,)"),
+			expectedErr: "1:8 unexpected character ',' in token starting at 1:2",
+		},
+		{
+			name:        "single semicolon after token",
+			input:       []byte("(module;)"),
+			expectedErr: "1:8 unexpected character ';' in token starting at 1:2",
+		},
+		{
+			name:        "unexpected unicode in token",
+			input:       []byte("(func $☺)"),
+			expectedErr: "1:8 unexpected character '☺' in token starting at 1:7",
+		},
+		{
+			name:        "unexpected unicode",
+			input:       []byte("(☺)"),
+			expectedErr: "1:2 unexpected character '☺'",
+		},
+		{
+			name:        "trailing underscore in number",
+			input:       []byte("(i32.const 100_)"),
+			expectedErr: "1:15 invalid underscore placement in number",
+		},
+		{
+			name:        "double underscore in number",
+			input:       []byte("1__0"),
+			expectedErr: "1:2 invalid underscore placement in number",
+		},
+		{
+			name:        "underscore after sign",
+			input:       []byte("+1_"),
+			expectedErr: "1:3 invalid underscore placement in number",
+		},
+		{
+			name:        "underscore after hex prefix",
+			input:       []byte("0x_1"),
+			expectedErr: "1:3 invalid underscore placement in number",
+		},
+		{
+			name:        "underscore before fraction",
+			input:       []byte("1_.5"),
+			expectedErr: "1:2 invalid underscore placement in number",
+		},
+		{
+			name:        "double underscore in nan payload",
+			input:       []byte("nan:0xf__f"),
+			expectedErr: "1:8 invalid underscore placement in number",
+		},
+		{
+			name:        "unterminated string",
+			input:       []byte(`(data "hello)`),
+			expectedErr: "1:7 expected string end '\"'",
+		},
+		{
+			name:        "unterminated string ending in an escaped quote",
+			input:       []byte(`"\"`),
+			expectedErr: "1:1 expected string end '\"'",
+		},
+		{
+			name:        "newline in string",
+			input:       []byte("\"a\nb\""),
+			expectedErr: "1:3 unexpected character '\\n'",
+		},
+		{
+			name:        "invalid UTF-8 in string",
+			input:       []byte("\"\xff\""),
+			expectedErr: "1:2 found an invalid byte in UTF-8 sequence: 0xff",
+		},
+		{
+			name:        "BOM after the beginning",
+			input:       []byte("(\xef\xbb\xbf)"),
+			expectedErr: "1:2 unexpected character '\\ufeff'",
+		},
+		{
+			name:        "CR in string",
+			input:       []byte("\"\r\""),
+			expectedErr: "1:2 unexpected character '\\r'",
+		},
+		{
+			name:        "invalid UTF-8 in block comment",
+			input:       []byte("(; \xff ;)"),
+			expectedErr: "1:4 found an invalid byte in UTF-8 sequence: 0xff",
+		},
+		{
+			name:        "invalid UTF-8 in line comment",
+			input:       []byte(";; \xff"),
+			expectedErr: "1:4 found an invalid byte in UTF-8 sequence: 0xff",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			err := lex(tc.input, LexOptions{}, func(source []byte, tok TokenType, beginLine, beginCol, beginPos, endPos int) error {
+				return nil
+			})
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestLex_BOM(t *testing.T) {
+	withBOM := string(append([]byte{0xef, 0xbb, 0xbf}, exampleWat...))
+
+	// Lines and columns are the same as without the BOM, while positions are shifted by its length.
+	expected := lexTokens(t, string(exampleWat))
+	for _, tok := range expected {
+		tok.pos += 3
+	}
+	require.Equal(t, expected, lexTokens(t, withBOM))
+
+	l := NewLexer([]byte(withBOM))
+	tok, err := l.Next()
+	require.NoError(t, err)
+	require.Equal(t, Token{Type: TokenLParen, Line: 1, Col: 1, BeginPos: 3, EndPos: 4}, tok)
+}
+
+func TestLexError(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *LexError
+	}{
+		{
+			name:     "unexpected character",
+			input:    "(module\n  ,)",
+			expected: &LexError{Line: 2, Col: 3, Pos: 10, Message: "unexpected character ','"},
+		},
+		{
+			name:     "unexpected character in token",
+			input:    "(module\n  abc,)",
+			expected: &LexError{Line: 2, Col: 6, Pos: 13, Message: "unexpected character ',' in token starting at 2:3"},
+		},
+		{
+			name:     "block comment",
+			input:    "(module\n  (; ☺",
+			expected: &LexError{Line: 2, Col: 3, Pos: 10, Message: "expected block comment end ';)'"},
+		},
+		{
+			name:     "string",
+			input:    `"☺` + "\x01",
+			expected: &LexError{Line: 1, Col: 3, Pos: 4, Message: "unexpected character '\\x01'"},
+		},
+		{
+			name:     "underscore",
+			input:    "(i32.const 1__0)",
+			expected: &LexError{Line: 1, Col: 13, Pos: 12, Message: "invalid underscore placement in number"},
+		},
+		{
+			name:     "UTF-8",
+			input:    ";; ☺\xff",
+			expected: &LexError{Line: 1, Col: 5, Pos: 6, Message: "found an invalid byte in UTF-8 sequence: 0xff"},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			err := lex([]byte(tc.input), LexOptions{}, func(source []byte, tok TokenType, beginLine, beginCol, beginPos, endPos int) error {
+				return nil
+			})
+			var lexErr *LexError
+			require.True(t, errors.As(err, &lexErr))
+			require.Equal(t, tc.expected, lexErr)
+		})
+	}
+}
+
+func TestLex_EOF(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *token
+	}{
+		{name: "empty", expected: &token{TokenEOF, 1, 1, 0, ""}},
+		{name: "after token", input: "(module)", expected: &token{TokenEOF, 1, 9, 8, ""}},
+		{name: "after newline", input: "(module)\n", expected: &token{TokenEOF, 2, 1, 9, ""}},
+		{name: "after line comment", input: ";; ☺", expected: &token{TokenEOF, 1, 5, 6, ""}},
+		{name: "example", input: string(exampleWat), expected: &token{TokenEOF, 16, 1, 427, ""}},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			var last *token
+			err := lex([]byte(tc.input), LexOptions{}, func(source []byte, tok TokenType, beginLine, beginCol, beginPos, endPos int) error {
+				require.Nil(t, last, "token after EOF")
+				if tok == TokenEOF {
+					last = &token{tok, beginLine, beginCol, beginPos, string(source[beginPos:endPos])}
+					require.Equal(t, len(source), endPos)
+				}
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, last)
+		})
+	}
+
+	t.Run("not emitted on error", func(t *testing.T) {
+		err := lex([]byte("(module (;"), LexOptions{}, func(source []byte, tok TokenType, beginLine, beginCol, beginPos, endPos int) error {
+			require.NotEqual(t, TokenEOF, tok)
+			return nil
+		})
+		require.EqualError(t, err, "1:9 expected block comment end ';)'")
+	})
+}
+
+func TestLexRange(t *testing.T) {
+	// Lex only the loop in exampleWat, which is lines 8-11.
+	start := strings.Index(string(exampleWat), "(loop")
+	end := strings.Index(string(exampleWat), "(i32.store")
+
+	var tokens []*token
+	err := LexRange(exampleWat, start, end, 8, 5, LexOptions{}, func(source []byte, tok TokenType, beginLine, beginCol, beginPos, endPos int) error {
+		tokens = append(tokens, &token{tok, beginLine, beginCol, beginPos, string(source[beginPos:endPos])})
+		return nil
+	})
+	require.NoError(t, err)
+
+	// The range must produce the same tokens as lexing the whole source.
+	all := lexTokens(t, string(exampleWat))
+	var expected []*token
+	for _, tok := range all {
+		if tok.pos >= start && tok.pos < end {
+			expected = append(expected, tok)
+		}
+	}
+	require.Equal(t, 41, len(expected))
+	// TokenEOF is at the end of the range, not the source.
+	expected = append(expected, &token{TokenEOF, 12, 5, end, ""})
+	require.Equal(t, expected, tokens)
+}
+
+func TestLexRange_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		start, end  int
+		expectedErr string
+	}{
+		{name: "negative start", input: "()", start: -1, end: 1, expectedErr: "invalid range [-1:1] of source with length 2"},
+		{name: "start after end", input: "()", start: 2, end: 1, expectedErr: "invalid range [2:1] of source with length 2"},
+		{name: "end after source", input: "()", start: 0, end: 3, expectedErr: "invalid range [0:3] of source with length 2"},
+		{
+			name:        "string straddles end",
+			input:       `(data "a b")`,
+			start:       0,
+			end:         8,
+			expectedErr: "1:7 expected string end '\"'",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			err := LexRange([]byte(tc.input), tc.start, tc.end, 1, 1, LexOptions{}, noopParser)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestLexer_Next(t *testing.T) {
+	l := NewLexer(exampleWat)
+	var tokens []*token
+	for {
+		tok, err := l.Next()
+		require.NoError(t, err)
+		if tok.Type == TokenEOF {
+			require.Equal(t, Token{Type: TokenEOF, Line: 16, Col: 1, BeginPos: len(exampleWat), EndPos: len(exampleWat)}, tok)
+			break
+		}
+		tokens = append(tokens, &token{tok.Type, tok.Line, tok.Col, tok.BeginPos, string(exampleWat[tok.BeginPos:tok.EndPos])})
+	}
+	require.Equal(t, lexTokens(t, string(exampleWat)), tokens)
+
+	// Calling Next after EOF returns EOF again.
+	tok, err := l.Next()
+	require.NoError(t, err)
+	require.Equal(t, TokenEOF, tok.Type)
+}
+
+func TestLex_BlockCommentDepth(t *testing.T) {
+	source := []byte(strings.Repeat("(;", 300))
+	err := lex(source, LexOptions{}, func([]byte, TokenType, int, int, int, int) error { return nil })
+	// The 256th opener is the first beyond the default depth.
+	require.Equal(t, &LexError{Line: 1, Col: 511, Pos: 510, Message: "block comment nested too deeply"}, err)
+
+	// A comment at the maximum depth is fine.
+	source = []byte(strings.Repeat("(;", DefaultMaxBlockCommentDepth) + strings.Repeat(";)", DefaultMaxBlockCommentDepth))
+	tok, err := NewLexer(source).Next()
+	require.NoError(t, err)
+	require.Equal(t, TokenEOF, tok.Type)
+}
+
+func TestLexer_MaxBlockCommentDepth(t *testing.T) {
+	l := NewLexer([]byte("(module\n(; (; ;) ;) (; (; (; ;) ;) ;)"))
+	l.MaxBlockCommentDepth = 2
+
+	tok, err := l.Next()
+	require.NoError(t, err)
+	require.Equal(t, TokenLParen, tok.Type)
+	tok, err = l.Next()
+	require.NoError(t, err)
+	require.Equal(t, TokenKeyword, tok.Type)
+
+	_, err = l.Next()
+	require.EqualError(t, err, "2:19 block comment nested too deeply")
+}
+
+func TestLexOptions_MaxBlockCommentDepth(t *testing.T) {
+	source := []byte("(module (; (; (; ;) ;) ;))")
+	opts := LexOptions{MaxBlockCommentDepth: 2}
+	expectedErr := "1:15 block comment nested too deeply"
+
+	require.EqualError(t, lex(source, opts, noopParser), expectedErr)
+	require.EqualError(t, LexRange(source, 0, len(source), 1, 1, opts, noopParser), expectedErr)
+	require.EqualError(t, LexReader(bytes.NewReader(source), opts, noopParser), expectedErr)
+	require.NoError(t, lex(source, LexOptions{MaxBlockCommentDepth: 3}, noopParser))
+}
+
+func TestLexOptions_NegativeMaxBlockCommentDepth(t *testing.T) {
+	opts := LexOptions{MaxBlockCommentDepth: -1}
+	expectedErr := "invalid MaxBlockCommentDepth -1"
+
+	require.EqualError(t, lex([]byte("(module)"), opts, noopParser), expectedErr)
+	require.EqualError(t, LexReader(strings.NewReader("(module)"), opts, noopParser), expectedErr)
+	l := NewLexer([]byte("(module)"))
+	l.LexOptions = opts
+	_, err := l.Next()
+	require.EqualError(t, err, expectedErr)
+}
+
+func TestLexer_Next_Error(t *testing.T) {
+	l := NewLexer([]byte("(module ,)"))
+
+	tok, err := l.Next()
+	require.NoError(t, err)
+	require.Equal(t, Token{Type: TokenLParen, Line: 1, Col: 1, BeginPos: 0, EndPos: 1}, tok)
+
+	tok, err = l.Next()
+	require.NoError(t, err)
+	require.Equal(t, Token{Type: TokenKeyword, Line: 1, Col: 2, BeginPos: 1, EndPos: 7}, tok)
+
+	_, err = l.Next()
+	require.EqualError(t, err, "1:9 unexpected character ','")
+}
+
+func TestLexReader(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "empty"},
+		{name: "example", input: string(exampleWat)},
+		{name: "BOM", input: "\xef\xbb\xbf(module)"},
+		{name: "long string", input: `(data "` + strings.Repeat("☺", 5000) + `")`},
+		{name: "long block comment", input: "(; " + strings.Repeat("(; ☺ ;)", 1000) + " ;)(module)"},
+		{name: "long line comment", input: ";; " + strings.Repeat("☺", 5000) + "\n(module)"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			expected := lexAllTokens(t, func(parser ParseToken) error { return lex([]byte(tc.input), LexOptions{}, parser) })
+
+			for _, r := range []struct {
+				name   string
+				reader io.Reader
+			}{
+				{name: "one byte", reader: iotest.OneByteReader(strings.NewReader(tc.input))},
+				{name: "half", reader: iotest.HalfReader(strings.NewReader(tc.input))},
+				{name: "data and EOF", reader: iotest.DataErrReader(strings.NewReader(tc.input))},
+			} {
+				actual := lexAllTokens(t, func(parser ParseToken) error { return LexReader(r.reader, LexOptions{}, parser) })
+				require.Equal(t, expected, actual, r.name)
+			}
+		})
+	}
+}
+
+func TestLexReader_Errors(t *testing.T) {
+	t.Run("lex error", func(t *testing.T) {
+		err := LexReader(iotest.OneByteReader(strings.NewReader(`(data "hello)`)), LexOptions{}, noopParser)
+		require.EqualError(t, err, "1:7 expected string end '\"'")
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		err := LexReader(iotest.TimeoutReader(iotest.OneByteReader(strings.NewReader("(module)"))), LexOptions{}, noopParser)
+		require.Equal(t, iotest.ErrTimeout, err)
+	})
+}
+
+// noopParser is a ParseToken that ignores all tokens.
+func noopParser(source []byte, tok TokenType, beginLine, beginCol, beginPos, endPos int) error {
+	return nil
+}
+
+// lexAllTokens returns the tokens the lex function passes to its parser, including TokenEOF.
+func lexAllTokens(t *testing.T, lex func(ParseToken) error) []*token {
+	var tokens []*token
+	err := lex(func(source []byte, tok TokenType, beginLine, beginCol, beginPos, endPos int) error {
+		tokens = append(tokens, &token{tok, beginLine, beginCol, beginPos, string(source[beginPos:endPos])})
+		return nil
+	})
+	require.NoError(t, err)
+	return tokens
+}
+
+func TestLex_ParserError(t *testing.T) {
+	expectedErr := errors.New("stop")
+	var count int
+	err := lex(exampleWat, LexOptions{}, func(source []byte, tok TokenType, beginLine, beginCol, beginPos, endPos int) error {
+		count++
+		if tok == TokenKeyword {
+			return expectedErr
+		}
+		return nil
+	})
+	require.Equal(t, expectedErr, err)
+	require.Equal(t, 2, count) // "(" then "module"
+}
+
+func TestIsIDChar(t *testing.T) {
+	for _, b := range []byte("$.~09azAZ_") {
+		require.True(t, IsIDChar(b), "%q", b)
+	}
+	for _, b := range []byte("\";,()[]{} \n\x7f\xe2") {
+		require.False(t, IsIDChar(b), "%q", b)
+	}
+}
+
+func TestIsWhitespace(t *testing.T) {
+	for _, b := range []byte(" \t\n\r") {
+		require.True(t, IsWhitespace(b), "%q", b)
+	}
+	for _, b := range []byte("\v\f\x00;a$") {
+		require.False(t, IsWhitespace(b), "%q", b)
+	}
+}
+
+func BenchmarkLex(b *testing.B) {
+	benchmarks := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", []byte{}},
+		{"whitespace chars", []byte(" \t\r\n")},
+		{"unicode block comment", []byte("(; 私たちはWASMが大好きです ;)")},
+		{"example", exampleWat},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := lex(bm.data, LexOptions{}, noopParser); err != nil {
+					panic(err)
+				}
+			}
+		})
+	}
+}
+
+// lexTokens lexes the input and returns the tokens in order, except TokenEOF, which TestLex_EOF covers. This fails the
+// test if there was an error.
+func lexTokens(t *testing.T, input string) []*token {
+	var tokens []*token
+	err := lex([]byte(input), LexOptions{}, func(source []byte, tok TokenType, beginLine, beginCol, beginPos, endPos int) error {
+		if tok != TokenEOF {
+			tokens = append(tokens, &token{tok, beginLine, beginCol, beginPos, string(source[beginPos:endPos])})
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	return tokens
+}
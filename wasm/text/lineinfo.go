@@ -0,0 +1,99 @@
+package text
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/tetratelabs/wazero/wasm/leb128"
+)
+
+// LineInfoSectionName is the name of the custom section that maps instructions to their position in the text format.
+// Its contents are returned by Module.EncodeLineInfo, and EncodeOptions.LineInfo adds it to a binary.
+const LineInfoSectionName = "wazero.lines"
+
+// FuncLineInfo are the positions of each instruction in a function.
+type FuncLineInfo struct {
+	// Func is the index of the function.
+	Func uint32
+	// Lines are in the order of their Offset.
+	Lines []*LineInfo
+}
+
+// LineInfo is the position in the text format of the instruction at Offset.
+type LineInfo struct {
+	// Offset is the byte offset of the instruction from the beginning of the function's body in the code section,
+	// which is the first byte after its size.
+	Offset uint32
+	// Line and Col are the position of the instruction's keyword, starting at 1.
+	Line, Col uint32
+}
+
+// EncodeLineInfo returns the contents of a custom section named LineInfoSectionName. This is much simpler than DWARF:
+// a vector of each function index, followed by a vector of the (offset, line, col) of each of its instructions. All
+// numbers are unsigned LEB128. The implicit "end" of a block, loop or function has no position.
+//
+// This returns an error for the same out of range indices as EncodeBinary. Use DecodeLineInfo to read the section
+// back, ex. from wasm.Module CustomSections.
+func (m *Module) EncodeLineInfo() ([]byte, error) {
+	funcs := make([]*FuncLineInfo, 0, len(m.Funcs))
+	for i, f := range m.Funcs {
+		fl := &FuncLineInfo{Func: uint32(i)}
+		if _, err := m.encodeFuncBody(f, &fl.Lines); err != nil {
+			return nil, fmt.Errorf("func[%d]: %w", i, err)
+		}
+		funcs = append(funcs, fl)
+	}
+	return encodeLineInfo(funcs), nil
+}
+
+// encodeLineInfo returns the contents of the custom section named LineInfoSectionName.
+func encodeLineInfo(funcs []*FuncLineInfo) []byte {
+	ret := leb128.EncodeUint32(uint32(len(funcs)))
+	for _, f := range funcs {
+		ret = append(ret, leb128.EncodeUint32(f.Func)...)
+		ret = append(ret, leb128.EncodeUint32(uint32(len(f.Lines)))...)
+		for _, l := range f.Lines {
+			ret = append(ret, leb128.EncodeUint32(l.Offset)...)
+			ret = append(ret, leb128.EncodeUint32(l.Line)...)
+			ret = append(ret, leb128.EncodeUint32(l.Col)...)
+		}
+	}
+	return ret
+}
+
+// DecodeLineInfo decodes the contents of a custom section returned by Module.EncodeLineInfo.
+func DecodeLineInfo(section []byte) ([]*FuncLineInfo, error) {
+	r := bytes.NewReader(section)
+	funcCount, _, err := leb128.DecodeUint32(r)
+	if err != nil {
+		return nil, fmt.Errorf("read function count: %w", err)
+	}
+
+	// Don't preallocate from funcCount, as it isn't trusted. Each function reads at least one byte, so a count larger
+	// than the section fails with EOF instead.
+	var ret []*FuncLineInfo
+	for i := uint32(0); i < funcCount; i++ {
+		f := &FuncLineInfo{}
+		if f.Func, _, err = leb128.DecodeUint32(r); err != nil {
+			return nil, fmt.Errorf("read function index of %d-th function: %w", i, err)
+		}
+		lineCount, _, err := leb128.DecodeUint32(r)
+		if err != nil {
+			return nil, fmt.Errorf("read line count of function %d: %w", f.Func, err)
+		}
+		for j := uint32(0); j < lineCount; j++ {
+			l := &LineInfo{}
+			for _, v := range []*uint32{&l.Offset, &l.Line, &l.Col} {
+				if *v, _, err = leb128.DecodeUint32(r); err != nil {
+					return nil, fmt.Errorf("read %d-th line of function %d: %w", j, f.Func, err)
+				}
+			}
+			f.Lines = append(f.Lines, l)
+		}
+		ret = append(ret, f)
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%d bytes after the last function", r.Len())
+	}
+	return ret, nil
+}
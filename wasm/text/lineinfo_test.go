@@ -0,0 +1,89 @@
+package text
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/wazero/wasm"
+)
+
+func TestModule_EncodeLineInfo(t *testing.T) {
+	m, err := Parse(exampleWat)
+	require.NoError(t, err)
+
+	section, err := m.EncodeLineInfo()
+	require.NoError(t, err)
+	actual, err := DecodeLineInfo(section)
+	require.NoError(t, err)
+	// Offsets begin after the three byte local declarations, and operands of a folded instruction come before it.
+	require.Equal(t, []*FuncLineInfo{{Func: 0, Lines: []*LineInfo{
+		{Offset: 3, Line: 5, Col: 19}, // i32.const 0
+		{Offset: 5, Line: 5, Col: 6},  // set_local 0
+		{Offset: 7, Line: 6, Col: 19},
+		{Offset: 9, Line: 6, Col: 6},
+		{Offset: 11, Line: 7, Col: 19},
+		{Offset: 13, Line: 7, Col: 6},
+		{Offset: 15, Line: 8, Col: 6},  // loop
+		{Offset: 17, Line: 9, Col: 30}, // get_local 0
+		{Offset: 19, Line: 9, Col: 57}, // get_local 1
+		{Offset: 21, Line: 9, Col: 44}, // tee_local 0
+		{Offset: 23, Line: 9, Col: 21}, // i32.add
+		{Offset: 24, Line: 9, Col: 8},  // set_local 1
+		{Offset: 26, Line: 10, Col: 39},
+		{Offset: 28, Line: 10, Col: 53},
+		{Offset: 30, Line: 10, Col: 30},
+		{Offset: 31, Line: 10, Col: 17},
+		{Offset: 33, Line: 10, Col: 8}, // br_if 0, which is followed by the end of the loop
+		{Offset: 36, Line: 12, Col: 17},
+		{Offset: 38, Line: 12, Col: 31},
+		{Offset: 40, Line: 12, Col: 6}, // i32.store
+	}}}, actual)
+}
+
+func TestModule_EncodeBinaryWithOptions_LineInfo(t *testing.T) {
+	m, err := Parse(exampleWat)
+	require.NoError(t, err)
+	expected, err := m.EncodeLineInfo()
+	require.NoError(t, err)
+	withoutLines, err := m.EncodeBinary()
+	require.NoError(t, err)
+
+	actual, err := m.EncodeBinaryWithOptions(EncodeOptions{LineInfo: true})
+	require.NoError(t, err)
+	// The custom section is added after the others, which are unchanged.
+	require.Equal(t, withoutLines, actual[:len(withoutLines)])
+
+	mod, err := wasm.DecodeModule(actual)
+	require.NoError(t, err)
+	require.Equal(t, expected, mod.CustomSections[LineInfoSectionName])
+	lines, err := DecodeLineInfo(mod.CustomSections[LineInfoSectionName])
+	require.NoError(t, err)
+	require.Equal(t, 1, len(lines))
+	require.Equal(t, 20, len(lines[0].Lines))
+}
+
+func TestDecodeLineInfo_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       []byte
+		expectedErr string
+	}{
+		{name: "empty", input: []byte{}, expectedErr: "read function count: EOF"},
+		{name: "no function index", input: []byte{1}, expectedErr: "read function index of 0-th function: EOF"},
+		{name: "no line count", input: []byte{1, 0}, expectedErr: "read line count of function 0: EOF"},
+		{name: "truncated line", input: []byte{1, 0, 1, 3, 5}, expectedErr: "read 0-th line of function 0: EOF"},
+		{name: "huge function count", input: []byte{0xff, 0xff, 0xff, 0xff, 0x0f}, expectedErr: "read function index of 0-th function: EOF"},
+		{name: "huge line count", input: []byte{1, 0, 0xff, 0xff, 0xff, 0xff, 0x0f}, expectedErr: "read 0-th line of function 0: EOF"},
+		{name: "trailing bytes", input: []byte{0, 0}, expectedErr: "1 bytes after the last function"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := DecodeLineInfo(tc.input)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package text
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// ErrConstantOutOfRange is returned when a number doesn't fit in the bit width it is parsed into.
+var ErrConstantOutOfRange = errors.New("constant out of range")
+
+// ParseUN parses the TokenUN at source[beginPos:endPos] into an unsigned integer of the given bit width, ex. 32 for
+// an i32. Decimal and "0x" prefixed hexadecimal digits are allowed, with underscores between them, ex. "0xFFFF_FFFF".
+//
+// This returns an error wrapping ErrConstantOutOfRange if the value doesn't fit in the bit width.
+//
+// See https://www.w3.org/TR/wasm-core-1/#integers%E2%91%A6
+func ParseUN(source []byte, beginPos, endPos int, bits int) (uint64, error) {
+	if bits < 1 || bits > 64 {
+		return 0, fmt.Errorf("invalid bit width %d", bits)
+	}
+	number := source[beginPos:endPos]
+	if len(number) == 0 || number[0] == '+' || number[0] == '-' {
+		return 0, fmt.Errorf("byte offset %d: invalid unsigned number %q", beginPos, number)
+	}
+	return parseDigits(number, beginPos, math.MaxUint64>>(64-bits))
+}
+
+// ParseSN parses the TokenSN or TokenUN at source[beginPos:endPos] into a signed integer of the given bit width, ex.
+// 32 for an i32. The number is formatted the same as ParseUN, except it may begin with a sign, ex. "-0x8000_0000".
+//
+// This returns an error wrapping ErrConstantOutOfRange if the value doesn't fit in the bit width.
+//
+// See https://www.w3.org/TR/wasm-core-1/#integers%E2%91%A6
+func ParseSN(source []byte, beginPos, endPos int, bits int) (int64, error) {
+	if bits < 1 || bits > 64 {
+		return 0, fmt.Errorf("invalid bit width %d", bits)
+	}
+	number := source[beginPos:endPos]
+	negative := len(number) > 0 && number[0] == '-'
+	offset := beginPos
+	if len(number) > 0 && (number[0] == '+' || number[0] == '-') {
+		number = number[1:]
+		offset++
+	}
+
+	// The magnitude of the minimum value is one more than the maximum, ex. -128 and 127 for 8 bits.
+	max := uint64(1)<<(bits-1) - 1
+	if negative {
+		max++
+	}
+	v, err := parseDigits(number, offset, max)
+	if err != nil {
+		return 0, err
+	}
+	if negative {
+		return -int64(v), nil // two's complement, so this is also correct for the minimum value
+	}
+	return int64(v), nil
+}
+
+// parseDigits parses the unsigned number, which begins at byte offset pos of the source, returning an error if it is
+// greater than max.
+func parseDigits(number []byte, pos int, max uint64) (uint64, error) {
+	if len(number) == 0 || invalidUnderscore(number) != -1 {
+		return 0, fmt.Errorf("byte offset %d: invalid number %q", pos, number)
+	}
+	base, digits := uint64(10), number
+	if len(number) > 2 && number[0] == '0' && number[1] == 'x' {
+		base, digits = 16, number[2:]
+	}
+
+	var v uint64
+	for _, b := range digits {
+		if b == '_' {
+			continue
+		}
+		var d uint64
+		switch {
+		case isDecimalDigit(b):
+			d = uint64(b - '0')
+		case base == 16 && isHexDigit(b):
+			d = uint64(hexValue(b))
+		default:
+			return 0, fmt.Errorf("byte offset %d: invalid number %q", pos, number)
+		}
+		if d > max || v > (max-d)/base {
+			return 0, fmt.Errorf("byte offset %d: %w: %s", pos, ErrConstantOutOfRange, number)
+		}
+		v = v*base + d
+	}
+	return v, nil
+}
+
+// ParseFN parses the TokenFN, TokenSN or TokenUN at source[beginPos:endPos] into a float of the given bit width, which
+// is 32 for an f32 or 64 for an f64. Decimal and hexadecimal floats are allowed, ex. "1.5e+3" and "-0x1.fp+4", as
+// well as "inf" and "nan" which may have a sign. A NaN has the canonical payload unless one is given, ex. "nan:0x200".
+//
+// An f32 is rounded to 32-bits, so float32 of the result is exact. When it is NaN, the payload is in the upper 23 bits
+// of the float64 mantissa. Note: converting a NaN to float32 sets its quiet bit on most platforms, so use EncodeFN
+// when the exact bits are needed.
+//
+// This returns an error wrapping ErrConstantOutOfRange if the value rounds to infinity or the NaN payload doesn't fit
+// in the mantissa.
+//
+// See https://www.w3.org/TR/wasm-core-1/#floating-point%E2%91%A6
+func ParseFN(source []byte, beginPos, endPos int, bits int) (float64, error) {
+	b, err := parseFNBits(source, beginPos, endPos, bits)
+	if err != nil {
+		return 0, err
+	}
+	if bits == 64 {
+		return math.Float64frombits(b), nil
+	}
+	if f := math.Float32frombits(uint32(b)); f == f {
+		return float64(f), nil
+	}
+	// Widen the NaN by hand, so that its payload isn't changed. The sign bit moves from bit 31 to 63, and the payload
+	// shifts up past the wider exponent.
+	return math.Float64frombits(b>>31<<63 | 0x7ff<<52 | (b&(1<<23-1))<<29), nil
+}
+
+// EncodeFN is like ParseFN, except it returns the float in the little-endian byte order of an "f32.const" or
+// "f64.const" immediate. Unlike ParseFN, this keeps the exact bits of any NaN.
+//
+// See https://www.w3.org/TR/wasm-core-1/#floating-point%E2%91%A0
+func EncodeFN(source []byte, beginPos, endPos int, bits int) ([]byte, error) {
+	b, err := parseFNBits(source, beginPos, endPos, bits)
+	if err != nil {
+		return nil, err
+	}
+	if bits == 32 {
+		ret := make([]byte, 4)
+		binary.LittleEndian.PutUint32(ret, uint32(b))
+		return ret, nil
+	}
+	ret := make([]byte, 8)
+	binary.LittleEndian.PutUint64(ret, b)
+	return ret, nil
+}
+
+// parseFNBits returns the IEEE 754 bits of the float at source[beginPos:endPos] in the given width. Only the lower 32
+// bits of the result are used when bits is 32.
+func parseFNBits(source []byte, beginPos, endPos int, bits int) (uint64, error) {
+	var mantissaBits uint
+	switch bits {
+	case 32:
+		mantissaBits = 23
+	case 64:
+		mantissaBits = 52
+	default:
+		return 0, fmt.Errorf("invalid bit width %d", bits)
+	}
+
+	number := source[beginPos:endPos]
+	negative := len(number) > 0 && number[0] == '-'
+	offset := beginPos
+	if len(number) > 0 && (number[0] == '+' || number[0] == '-') {
+		number = number[1:]
+		offset++
+	}
+
+	var sign uint64
+	if negative {
+		sign = 1 << (bits - 1)
+	}
+	// The exponent is all ones for both infinity and NaN. It is 8 bits for an f32 and 11 for an f64.
+	exponent := (uint64(1)<<(bits-1) - 1) &^ (1<<mantissaBits - 1)
+	switch {
+	case bytes.Equal(number, inf):
+		return sign | exponent, nil
+	case bytes.Equal(number, nan), bytes.HasPrefix(number, nanPrefix):
+		payload := uint64(1) << (mantissaBits - 1) // canonical NaN
+		if len(number) > len(nan) {
+			var err error
+			// Skip "nan:" so that the payload is parsed as hexadecimal.
+			if payload, err = parseDigits(number[len("nan:"):], offset+len("nan:"), 1<<mantissaBits-1); err != nil {
+				return 0, err
+			} else if payload == 0 {
+				return 0, fmt.Errorf("byte offset %d: invalid number %q", offset, number) // that would be infinity
+			}
+		}
+		return sign | exponent | payload, nil
+	}
+
+	// Unlike strconv.ParseFloat, the number must begin with a digit, a hex float needn't have an exponent and
+	// underscores are allowed between any digits.
+	hex := len(number) > 2 && number[0] == '0' && number[1] == 'x'
+	if len(number) == 0 || !isDecimalDigit(number[0]) || (hex && !isHexDigit(number[2])) || invalidUnderscore(number) != -1 {
+		return 0, fmt.Errorf("byte offset %d: invalid number %q", offset, number)
+	}
+	digits := make([]byte, 0, len(number)+3)
+	if negative {
+		digits = append(digits, '-')
+	}
+	for _, b := range number {
+		if b != '_' {
+			digits = append(digits, b)
+		}
+	}
+	if hex && bytes.IndexAny(number, "pP") == -1 {
+		digits = append(digits, 'p', '0')
+	}
+
+	v, err := strconv.ParseFloat(string(digits), bits)
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("byte offset %d: %w: %s", offset, ErrConstantOutOfRange, number)
+	} else if err != nil {
+		return 0, fmt.Errorf("byte offset %d: invalid number %q", offset, number)
+	}
+	if bits == 32 {
+		return uint64(math.Float32bits(float32(v))), nil
+	}
+	return math.Float64bits(v), nil
+}
@@ -0,0 +1,256 @@
+package text
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUN(t *testing.T) {
+	tests := []struct {
+		input    string
+		bits     int
+		expected uint64
+	}{
+		{input: "0", bits: 32},
+		{input: "10", bits: 32, expected: 10},
+		{input: "1_000", bits: 32, expected: 1000},
+		{input: "0x0a", bits: 32, expected: 10},
+		{input: "0xFFFFFFFF", bits: 32, expected: math.MaxUint32},
+		{input: "4294967295", bits: 32, expected: math.MaxUint32},
+		{input: "0x1_0000_0000", bits: 64, expected: 1 << 32},
+		{input: "0xffff_ffff_ffff_ffff", bits: 64, expected: math.MaxUint64},
+		{input: "18446744073709551615", bits: 64, expected: math.MaxUint64},
+		{input: "1_000_000_000_000", bits: 64, expected: 1_000_000_000_000},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.input, func(t *testing.T) {
+			actual, err := ParseUN([]byte(tc.input), 0, len(tc.input), tc.bits)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestParseSN(t *testing.T) {
+	tests := []struct {
+		input    string
+		bits     int
+		expected int64
+	}{
+		{input: "0", bits: 32},
+		{input: "-0", bits: 32},
+		{input: "+10", bits: 32, expected: 10},
+		{input: "10", bits: 32, expected: 10},
+		{input: "-1_000", bits: 32, expected: -1000},
+		{input: "-0x1F", bits: 32, expected: -31},
+		{input: "+2147483647", bits: 32, expected: math.MaxInt32},
+		{input: "-2147483648", bits: 32, expected: math.MinInt32},
+		{input: "-0x8000_0000", bits: 32, expected: math.MinInt32},
+		{input: "+0x7fff_ffff_ffff_ffff", bits: 64, expected: math.MaxInt64},
+		{input: "-9223372036854775808", bits: 64, expected: math.MinInt64},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.input, func(t *testing.T) {
+			actual, err := ParseSN([]byte(tc.input), 0, len(tc.input), tc.bits)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestParseUN_Errors(t *testing.T) {
+	tests := []struct {
+		name, input string
+		bits        int
+		expectedErr string
+	}{
+		{name: "hex overflow", input: "0x1_0000_0000", bits: 32, expectedErr: "byte offset 0: constant out of range: 0x1_0000_0000"},
+		{name: "decimal overflow", input: "4294967296", bits: 32, expectedErr: "byte offset 0: constant out of range: 4294967296"},
+		{name: "64-bit overflow", input: "18446744073709551616", bits: 64, expectedErr: "byte offset 0: constant out of range: 18446744073709551616"},
+		{name: "signed", input: "+1", bits: 32, expectedErr: `byte offset 0: invalid unsigned number "+1"`},
+		{name: "hex prefix only", input: "0x", bits: 32, expectedErr: `byte offset 0: invalid number "0x"`},
+		{name: "letters", input: "1abc", bits: 32, expectedErr: `byte offset 0: invalid number "1abc"`},
+		{name: "misplaced underscore", input: "1__0", bits: 32, expectedErr: `byte offset 0: invalid number "1__0"`},
+		{name: "bit width", input: "1", bits: 65, expectedErr: "invalid bit width 65"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseUN([]byte(tc.input), 0, len(tc.input), tc.bits)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestParseSN_Errors(t *testing.T) {
+	tests := []struct {
+		name, input string
+		bits        int
+		expectedErr string
+	}{
+		{name: "positive overflow", input: "+2147483648", bits: 32, expectedErr: "byte offset 1: constant out of range: 2147483648"},
+		{name: "negative overflow", input: "-2147483649", bits: 32, expectedErr: "byte offset 1: constant out of range: 2147483649"},
+		{name: "64-bit overflow", input: "-0x8000_0000_0000_0001", bits: 64, expectedErr: "byte offset 1: constant out of range: 0x8000_0000_0000_0001"},
+		{name: "sign only", input: "-", bits: 32, expectedErr: `byte offset 1: invalid number ""`},
+		{name: "bit width", input: "1", bits: 0, expectedErr: "invalid bit width 0"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseSN([]byte(tc.input), 0, len(tc.input), tc.bits)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestParseUN_Position(t *testing.T) {
+	source := []byte("(i32.const 0x1_0000_0000)")
+	_, err := ParseUN(source, 11, 24, 32)
+	require.EqualError(t, err, "byte offset 11: constant out of range: 0x1_0000_0000")
+	require.True(t, errors.Is(err, ErrConstantOutOfRange))
+
+	v, err := ParseUN(source, 11, 14, 32) // "0x1"
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), v)
+}
+
+func TestParseFN(t *testing.T) {
+	tests := []struct {
+		input    string
+		bits     int
+		expected uint64 // bits of the float, as a NaN isn't equal to itself
+	}{
+		{input: "0", bits: 64},
+		{input: "-0.0", bits: 64, expected: 1 << 63},
+		{input: "1.5", bits: 64, expected: math.Float64bits(1.5)},
+		{input: "1_000.000_5e-1_0", bits: 64, expected: math.Float64bits(1000.0005e-10)},
+		{input: "+1.e2", bits: 64, expected: math.Float64bits(100)},
+		{input: "0x1.fp+4", bits: 64, expected: math.Float64bits(31)},
+		{input: "0x1.f", bits: 64, expected: math.Float64bits(1.9375)},
+		{input: "0xA_B.cp-1", bits: 64, expected: math.Float64bits(85.875)},
+		{input: "0x1p-1074", bits: 64, expected: 1},
+		{input: "inf", bits: 64, expected: math.Float64bits(math.Inf(1))},
+		{input: "-inf", bits: 64, expected: math.Float64bits(math.Inf(-1))},
+		{input: "nan", bits: 64, expected: 0x7ff8_0000_0000_0000},
+		{input: "+nan", bits: 64, expected: 0x7ff8_0000_0000_0000},
+		{input: "nan:0x1", bits: 64, expected: 0x7ff0_0000_0000_0001},
+		{input: "nan:0xf_ffff_ffff_ffff", bits: 64, expected: 0x7fff_ffff_ffff_ffff},
+		// f32 results are rounded as float32, so they are compared as float32 bits.
+		{input: "0.1", bits: 32, expected: uint64(math.Float32bits(0.1))},
+		{input: "16777217", bits: 32, expected: 0x4b80_0000}, // rounds to even: 16777216
+		{input: "0x1p-149", bits: 32, expected: uint64(math.Float32bits(math.Float32frombits(1)))},
+		{input: "0x1.fffffep127", bits: 32, expected: uint64(math.Float32bits(math.MaxFloat32))},
+		{input: "0x1p-150", bits: 32}, // rounds to zero
+		{input: "nan", bits: 32, expected: 0x7fc0_0000},
+		{input: "-nan", bits: 32, expected: 0xffc0_0000},
+		{input: "nan:0x400000", bits: 32, expected: 0x7fc0_0000},
+		{input: "-nan:0x7fffff", bits: 32, expected: 0xffff_ffff},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.input, func(t *testing.T) {
+			actual, err := ParseFN([]byte(tc.input), 0, len(tc.input), tc.bits)
+			require.NoError(t, err)
+			if tc.bits == 32 {
+				require.Equal(t, uint32(tc.expected), math.Float32bits(float32(actual)))
+			} else {
+				require.Equal(t, tc.expected, math.Float64bits(actual))
+			}
+		})
+	}
+}
+
+func TestParseFN_Errors(t *testing.T) {
+	tests := []struct {
+		name, input string
+		bits        int
+		expectedErr string
+	}{
+		{name: "f32 overflow", input: "0x1p128", bits: 32, expectedErr: "byte offset 0: constant out of range: 0x1p128"},
+		{name: "f64 overflow", input: "-1e309", bits: 64, expectedErr: "byte offset 1: constant out of range: 1e309"},
+		{name: "f32 payload overflow", input: "nan:0x800000", bits: 32, expectedErr: "byte offset 4: constant out of range: 0x800000"},
+		{name: "zero payload", input: "nan:0x0", bits: 64, expectedErr: `byte offset 0: invalid number "nan:0x0"`},
+		{name: "no leading digit", input: ".5", bits: 64, expectedErr: `byte offset 0: invalid number ".5"`},
+		{name: "no leading hex digit", input: "0x.8", bits: 64, expectedErr: `byte offset 0: invalid number "0x.8"`},
+		{name: "misplaced underscore", input: "1_.5", bits: 64, expectedErr: `byte offset 0: invalid number "1_.5"`},
+		{name: "infinity", input: "infinity", bits: 64, expectedErr: `byte offset 0: invalid number "infinity"`},
+		{name: "no exponent", input: "1e", bits: 64, expectedErr: `byte offset 0: invalid number "1e"`},
+		{name: "bit width", input: "1", bits: 16, expectedErr: "invalid bit width 16"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseFN([]byte(tc.input), 0, len(tc.input), tc.bits)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestEncodeFN(t *testing.T) {
+	tests := []struct {
+		input    string
+		bits     int
+		expected []byte
+	}{
+		{input: "1.5", bits: 32, expected: []byte{0x00, 0x00, 0xc0, 0x3f}},
+		{input: "-inf", bits: 32, expected: []byte{0x00, 0x00, 0x80, 0xff}},
+		{input: "nan", bits: 32, expected: []byte{0x00, 0x00, 0xc0, 0x7f}},
+		{input: "-nan", bits: 32, expected: []byte{0x00, 0x00, 0xc0, 0xff}},
+		{input: "nan:0x400000", bits: 32, expected: []byte{0x00, 0x00, 0xc0, 0x7f}},
+		{input: "nan:0x200000", bits: 32, expected: []byte{0x00, 0x00, 0xa0, 0x7f}}, // signaling, so not quieted
+		{input: "-nan:0x1", bits: 32, expected: []byte{0x01, 0x00, 0x80, 0xff}},
+		{input: "1.5", bits: 64, expected: []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0x3f}},
+		{input: "+nan", bits: 64, expected: []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0x7f}},
+		{input: "-nan", bits: 64, expected: []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0xff}},
+		{input: "nan:0x4_0000_0000_0000", bits: 64, expected: []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf4, 0x7f}},
+		{input: "-nan:0xf_ffff_ffff_ffff", bits: 64, expected: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.input, func(t *testing.T) {
+			actual, err := EncodeFN([]byte(tc.input), 0, len(tc.input), tc.bits)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestEncodeFN_Errors(t *testing.T) {
+	tests := []struct {
+		name, input string
+		bits        int
+		expectedErr string
+	}{
+		{name: "f32 payload overflow", input: "-nan:0x800000", bits: 32, expectedErr: "byte offset 5: constant out of range: 0x800000"},
+		{name: "f64 payload overflow", input: "+nan:0x10_0000_0000_0000", bits: 64, expectedErr: "byte offset 5: constant out of range: 0x10_0000_0000_0000"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := EncodeFN([]byte(tc.input), 0, len(tc.input), tc.bits)
+			require.EqualError(t, err, tc.expectedErr)
+			require.True(t, errors.Is(err, ErrConstantOutOfRange))
+		})
+	}
+}
@@ -0,0 +1,1021 @@
+package text
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+
+	"github.com/tetratelabs/wazero/wasm"
+)
+
+// ParseError is returned when the source lexes, but isn't a valid module. Its Error is formatted as
+// "line:col message", where the position is the token the parser didn't expect.
+type ParseError struct {
+	// Line is the line number of the token, starting at 1.
+	Line int
+	// Col is the column number of the token, starting at 1.
+	Col int
+	// Pos is the byte position in the source of the token.
+	Pos int
+	// Message describes the error, ex. "unknown func $main".
+	Message string
+}
+
+// Error implements error.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%d:%d %s", e.Line, e.Col, e.Message)
+}
+
+// Parse parses a module in the WebAssembly text format into its abstract syntax tree. This returns a LexError if the
+// source isn't lexically valid, or a ParseError if it isn't a valid module.
+//
+// Only a subset of the text format is supported so far: type, memory, func, data, start and export fields, with
+// params, results, locals and plain or folded instructions, including block and loop. For example, instructions with a
+// table or a global are not yet supported. A type must be defined before a func uses it with "(type x)". The legacy
+// instruction names, ex. "get_local" instead of "local.get", are accepted.
+//
+// See https://www.w3.org/TR/wasm-core-1/#text-format%E2%91%A0
+func Parse(source []byte) (*Module, error) {
+	return ParseWithOptions(source, ParseOptions{})
+}
+
+// ParseOptions limit the size of a module accepted by ParseWithOptions, which bounds the memory used to parse untrusted
+// source. The zero value has no limits, except the defaults of LexOptions.
+type ParseOptions struct {
+	LexOptions
+
+	// MaxFuncs is the maximum count of func fields, or no limit when zero.
+	MaxFuncs int
+	// MaxExports is the maximum count of export fields, or no limit when zero.
+	MaxExports int
+	// MaxInstructions is the maximum count of instructions in all funcs and data offsets, or no limit when zero. As
+	// each enclosing block or folded instruction is also an instruction, this limits their nesting, too.
+	MaxInstructions int
+}
+
+// ParseWithOptions is like Parse, except it returns a ParseError at the first field or instruction exceeding a limit
+// in the options. A negative limit is an error.
+func ParseWithOptions(source []byte, opts ParseOptions) (*Module, error) {
+	if err := opts.LexOptions.validate(); err != nil {
+		return nil, err
+	}
+	for _, limit := range []struct {
+		name  string
+		value int
+	}{{"MaxFuncs", opts.MaxFuncs}, {"MaxExports", opts.MaxExports}, {"MaxInstructions", opts.MaxInstructions}} {
+		if limit.value < 0 {
+			return nil, fmt.Errorf("invalid %s %d", limit.name, limit.value)
+		}
+	}
+
+	p := &parser{
+		source:    source,
+		lexer:     NewLexer(source),
+		opts:      opts,
+		module:    &Module{},
+		funcIDs:   map[string]uint32{},
+		memoryIDs: map[string]uint32{},
+		typeIDs:   map[string]uint32{},
+	}
+	p.lexer.LexOptions = opts.LexOptions
+	if err := p.parseModule(); err != nil {
+		return nil, err
+	}
+	return p.module, nil
+}
+
+// parser reads tokens from a Lexer one at a time. Each parse function begins at its first token and returns after
+// reading its last.
+type parser struct {
+	source []byte
+	lexer  *Lexer
+	// tok is the current token, which hasn't been consumed yet.
+	tok    Token
+	opts   ParseOptions
+	module *Module
+	// instructionCount is the count of instructions parsed so far, to enforce ParseOptions.MaxInstructions.
+	instructionCount int
+
+	// funcIDs and memoryIDs are the symbol tables of each index space in the module. Fields can be referenced before
+	// they are defined, so references are resolved after the module is read.
+	funcIDs, memoryIDs map[string]uint32
+	references         []*reference
+	// typeIDs is the symbol table of types, which must be defined before they are used.
+	typeIDs map[string]uint32
+	// inlineTypes are the funcs whose type index is resolved after the module is read.
+	inlineTypes []*inlineType
+
+	// localIDs is the symbol table of the locals in the current function.
+	localIDs map[string]uint32
+	// labels are the labels of the blocks enclosing the current instruction, innermost last. An empty string is a
+	// block without a label.
+	labels []string
+}
+
+// reference is a TokenID that refers to a module field, which is resolved by calling set with the index of it.
+type reference struct {
+	tok  Token
+	kind string // "func" or "memory"
+	set  func(index uint32)
+}
+
+func (p *parser) next() error {
+	tok, err := p.lexer.Next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+// text returns the source of the current token.
+func (p *parser) text() string {
+	return string(p.source[p.tok.BeginPos:p.tok.EndPos])
+}
+
+func (p *parser) isKeyword(keyword string) bool {
+	return p.tok.Type == TokenKeyword && string(p.source[p.tok.BeginPos:p.tok.EndPos]) == keyword
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &ParseError{Line: p.tok.Line, Col: p.tok.Col, Pos: p.tok.BeginPos, Message: fmt.Sprintf(format, args...)}
+}
+
+// tokenError returns an error from decoding the current token, ex. by ParseUN or decodeStringBytes. The byte offset is
+// removed from the message, as the ParseError already has the position.
+func (p *parser) tokenError(err error) error {
+	msg := err.Error()
+	if strings.HasPrefix(msg, "byte offset ") {
+		msg = msg[strings.Index(msg, ": ")+2:]
+	}
+	return p.errorf("%s", msg)
+}
+
+// unexpected returns an error describing the current token, ex. "expected ')', but got keyword nop".
+func (p *parser) unexpected(expected string) error {
+	switch p.tok.Type {
+	case TokenLParen, TokenRParen:
+		return p.errorf("expected %s, but got '%s'", expected, p.tok.Type)
+	case TokenEOF:
+		return p.errorf("expected %s, but got EOF", expected)
+	}
+	return p.errorf("expected %s, but got %s %s", expected, p.tok.Type, p.text())
+}
+
+// expectRParen consumes the ')' that ends the current s-expression.
+func (p *parser) expectRParen() error {
+	if p.tok.Type != TokenRParen {
+		return p.unexpected("')'")
+	}
+	return p.next()
+}
+
+// optionalID returns the current TokenID, consuming it, or an empty string if the current token isn't one.
+func (p *parser) optionalID() (string, error) {
+	if p.tok.Type != TokenID {
+		return "", nil
+	}
+	id := p.text()
+	return id, p.next()
+}
+
+// defineID adds the current TokenID to the symbol table, consuming it. Each index space is a separate namespace, so
+// the same id can name, ex. both a func and a memory.
+func (p *parser) defineID(ids map[string]uint32, kind string, index uint32) (string, error) {
+	id := p.text()
+	if _, ok := ids[id]; ok {
+		return "", p.errorf("duplicate %s %s", kind, id)
+	}
+	ids[id] = index
+	return id, p.next()
+}
+
+// defineOptionalID is like defineID, except it returns an empty string if the current token isn't a TokenID.
+func (p *parser) defineOptionalID(ids map[string]uint32, kind string, index uint32) (string, error) {
+	if p.tok.Type != TokenID {
+		return "", nil
+	}
+	return p.defineID(ids, kind, index)
+}
+
+// parseU32 consumes the current TokenUN as a uint32.
+func (p *parser) parseU32(expected string) (uint32, error) {
+	if p.tok.Type != TokenUN {
+		return 0, p.unexpected(expected)
+	}
+	v, err := ParseUN(p.source, p.tok.BeginPos, p.tok.EndPos, 32)
+	if err != nil {
+		return 0, p.tokenError(err)
+	}
+	return uint32(v), p.next()
+}
+
+// parseIndex consumes the current TokenUN or TokenID as an index of the given kind of module field. A TokenID is
+// resolved after the module is read, by calling set.
+func (p *parser) parseIndex(kind string, set func(uint32)) error {
+	if p.tok.Type == TokenID {
+		p.references = append(p.references, &reference{tok: p.tok, kind: kind, set: set})
+		return p.next()
+	}
+	index, err := p.parseU32(kind + " index")
+	if err != nil {
+		return err
+	}
+	set(index)
+	return nil
+}
+
+func (p *parser) parseModule() error {
+	if err := p.next(); err != nil {
+		return err
+	}
+	if p.tok.Type != TokenLParen {
+		return p.unexpected("'('")
+	}
+	if err := p.next(); err != nil {
+		return err
+	}
+	if !p.isKeyword("module") {
+		return p.unexpected("module")
+	}
+	if err := p.next(); err != nil {
+		return err
+	}
+	var err error
+	if p.module.ID, err = p.optionalID(); err != nil {
+		return err
+	}
+
+	for p.tok.Type == TokenLParen {
+		if err = p.next(); err != nil {
+			return err
+		}
+		if p.tok.Type != TokenKeyword {
+			return p.unexpected("a module field")
+		}
+		switch field := p.text(); field {
+		case "type":
+			err = p.parseType()
+		case "memory":
+			err = p.parseMemory()
+		case "func":
+			err = p.parseFunc()
+		case "data":
+			err = p.parseData()
+		case "start":
+			err = p.parseStart()
+		case "export":
+			err = p.parseExport()
+		default:
+			err = p.errorf("unsupported module field %s", field)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err = p.expectRParen(); err != nil {
+		return err
+	}
+	if p.tok.Type != TokenEOF {
+		return p.unexpected("EOF")
+	}
+	if err = p.resolveReferences(); err != nil {
+		return err
+	}
+	p.resolveInlineTypes()
+	return nil
+}
+
+func (p *parser) resolveReferences() error {
+	for _, r := range p.references {
+		ids := p.funcIDs
+		if r.kind == "memory" {
+			ids = p.memoryIDs
+		}
+		id := string(p.source[r.tok.BeginPos:r.tok.EndPos])
+		index, ok := ids[id]
+		if !ok {
+			p.tok = r.tok
+			return p.errorf("unknown %s %s", r.kind, id)
+		}
+		r.set(index)
+	}
+	return nil
+}
+
+// parseMemory parses "memory id? min max? )", where the "(" was already consumed.
+func (p *parser) parseMemory() error {
+	m := &Memory{Line: p.tok.Line, Col: p.tok.Col}
+	if err := p.next(); err != nil {
+		return err
+	}
+	var err error
+	if m.ID, err = p.defineOptionalID(p.memoryIDs, "memory", uint32(len(p.module.Memories))); err != nil {
+		return err
+	}
+	if m.Min, err = p.parseU32("memory min"); err != nil {
+		return err
+	}
+	if p.tok.Type == TokenUN {
+		max, err := p.parseU32("memory max")
+		if err != nil {
+			return err
+		}
+		m.Max = &max
+	}
+	p.module.Memories = append(p.module.Memories, m)
+	return p.expectRParen()
+}
+
+// parseExport parses "export name (func funcidx) )" or the same with memory, where the "(" was already consumed.
+func (p *parser) parseExport() error {
+	if max := p.opts.MaxExports; max > 0 && len(p.module.Exports) == max {
+		return p.errorf("too many exports: the limit is %d", max)
+	}
+	e := &Export{Line: p.tok.Line, Col: p.tok.Col}
+	if err := p.next(); err != nil {
+		return err
+	}
+	if p.tok.Type != TokenString {
+		return p.unexpected("export name")
+	}
+	name, err := decodeStringUTF8(p.source, p.tok.BeginPos, p.tok.EndPos)
+	if err != nil {
+		return p.tokenError(err)
+	}
+	e.Name = name
+	for _, other := range p.module.Exports {
+		if other.Name == e.Name {
+			return p.errorf("duplicate export %q", e.Name)
+		}
+	}
+	if err = p.next(); err != nil {
+		return err
+	}
+
+	if p.tok.Type != TokenLParen {
+		return p.unexpected("export description")
+	}
+	if err = p.next(); err != nil {
+		return err
+	}
+	var kind string
+	switch {
+	case p.isKeyword("func"):
+		e.Kind, kind = wasm.ExportKindFunction, "func"
+	case p.isKeyword("memory"):
+		e.Kind, kind = wasm.ExportKindMemory, "memory"
+	case p.tok.Type == TokenKeyword:
+		return p.errorf("unsupported export %s", p.text())
+	default:
+		return p.unexpected("export description")
+	}
+	if err = p.next(); err != nil {
+		return err
+	}
+	if err = p.parseIndex(kind, func(index uint32) { e.Index = index }); err != nil {
+		return err
+	}
+	if err = p.expectRParen(); err != nil {
+		return err
+	}
+	p.module.Exports = append(p.module.Exports, e)
+	return p.expectRParen()
+}
+
+// parseStart parses "start funcidx )", where the "(" was already consumed.
+func (p *parser) parseStart() error {
+	if p.module.Start != nil {
+		return p.errorf("duplicate start")
+	}
+	if err := p.next(); err != nil {
+		return err
+	}
+	if err := p.parseIndex("func", func(index uint32) { p.module.Start = &index }); err != nil {
+		return err
+	}
+	return p.expectRParen()
+}
+
+// parseData parses "data memidx? offset string* )", where the "(" was already consumed. The offset is either
+// "(offset instr*)" or a single folded instruction, ex. "(i32.const 0)".
+func (p *parser) parseData() error {
+	d := &Data{Line: p.tok.Line, Col: p.tok.Col}
+	if err := p.next(); err != nil {
+		return err
+	}
+	if p.tok.Type == TokenUN || p.tok.Type == TokenID {
+		if err := p.parseIndex("memory", func(index uint32) { d.Memory = index }); err != nil {
+			return err
+		}
+	}
+
+	if p.tok.Type != TokenLParen {
+		return p.unexpected("offset")
+	}
+	if err := p.next(); err != nil {
+		return err
+	}
+	var err error
+	if p.isKeyword("offset") {
+		if err = p.next(); err != nil {
+			return err
+		}
+		if d.Offset, err = p.parseInstructions(); err != nil {
+			return err
+		}
+		if err = p.expectRParen(); err != nil {
+			return err
+		}
+	} else {
+		inst, err := p.parseFoldedInstruction()
+		if err != nil {
+			return err
+		}
+		d.Offset = []*Instruction{inst}
+	}
+
+	for p.tok.Type == TokenString {
+		b, err := decodeStringBytes(p.source, p.tok.BeginPos, p.tok.EndPos)
+		if err != nil {
+			return p.tokenError(err)
+		}
+		d.Init = append(d.Init, b...)
+		if err = p.next(); err != nil {
+			return err
+		}
+	}
+	p.module.Data = append(p.module.Data, d)
+	return p.expectRParen()
+}
+
+// parseType parses "type id? (func (param ...)* (result ...)*) )", where the "(" was already consumed.
+func (p *parser) parseType() error {
+	if err := p.next(); err != nil {
+		return err
+	}
+	typeIndex := uint32(len(p.module.Types))
+	id, err := p.defineOptionalID(p.typeIDs, "type", typeIndex)
+	if err != nil {
+		return err
+	}
+	if p.tok.Type != TokenLParen {
+		return p.unexpected("'('")
+	}
+	if err = p.next(); err != nil {
+		return err
+	}
+	if !p.isKeyword("func") {
+		return p.unexpected("func")
+	}
+	if err = p.next(); err != nil {
+		return err
+	}
+
+	t := &FuncType{ID: id}
+	paramIDs, hasResult := map[string]uint32{}, false
+	for p.tok.Type == TokenLParen {
+		if err = p.next(); err != nil {
+			return err
+		}
+		var params []*Local
+		switch {
+		case p.isKeyword("param") && !hasResult:
+			// Params can be named, but the names aren't used outside a func.
+			params, err = p.parseValueTypes(paramIDs, "param", len(t.Params))
+			t.Params = appendLocalTypes(t.Params, params)
+		case p.isKeyword("result"):
+			t.Results, err = p.parseResults(t.Results)
+			hasResult = true
+		default:
+			return p.unexpected("param or result")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err = p.expectRParen(); err != nil { // the end of the func
+		return err
+	}
+	p.module.Types = append(p.module.Types, t)
+	return p.expectRParen()
+}
+
+// parseFunc parses "func id? typeuse local* instr* )", where the "(" was already consumed. The typeuse is an optional
+// "(type x)", which must already be defined, followed by inline params and results.
+//
+// See https://www.w3.org/TR/wasm-core-1/#type-uses%E2%91%A0
+func (p *parser) parseFunc() error {
+	if max := p.opts.MaxFuncs; max > 0 && len(p.module.Funcs) == max {
+		return p.errorf("too many funcs: the limit is %d", max)
+	}
+	f := &Func{Line: p.tok.Line, Col: p.tok.Col}
+	if err := p.next(); err != nil {
+		return err
+	}
+	var err error
+	if f.ID, err = p.defineOptionalID(p.funcIDs, "func", uint32(len(p.module.Funcs))); err != nil {
+		return err
+	}
+	p.localIDs, p.labels = map[string]uint32{}, nil
+
+	// The typeuse and locals come first, but a "(" may instead begin a folded instruction, which is only known after
+	// the keyword. Each must be in order, ex. a result can't be before a param.
+	var typeUse *FuncType
+	var typeUseTok Token
+	inline := &FuncType{}
+	hasInline := false
+	order := 0
+	for p.tok.Type == TokenLParen {
+		if err = p.next(); err != nil {
+			return err
+		}
+		keywordOrder := 0
+		for i, keyword := range []string{"type", "param", "result", "local"} {
+			if p.isKeyword(keyword) {
+				keywordOrder = i + 1
+			}
+		}
+		if keywordOrder == 0 {
+			inst, err := p.parseFoldedInstruction()
+			if err != nil {
+				return err
+			}
+			f.Body = append(f.Body, inst)
+			break
+		}
+		if keywordOrder < order || (keywordOrder == 1 && order == 1) {
+			return p.errorf("unexpected %s", p.text())
+		}
+		order = keywordOrder
+
+		switch keywordOrder {
+		case 1:
+			typeUseTok = p.tok
+			if typeUse, err = p.parseTypeUse(f); err != nil {
+				return err
+			}
+		case 2:
+			var params []*Local
+			if params, err = p.parseValueTypes(p.localIDs, "param", len(f.Params)); err != nil {
+				return err
+			}
+			f.Params = append(f.Params, params...)
+			inline.Params, hasInline = appendLocalTypes(inline.Params, params), true
+		case 3:
+			if inline.Results, err = p.parseResults(inline.Results); err != nil {
+				return err
+			}
+			hasInline = true
+		case 4:
+			// The params are known once locals begin, and local indices follow them.
+			if f.Params == nil && typeUse != nil {
+				f.Params = typeUseParams(typeUse)
+			}
+			var locals []*Local
+			if locals, err = p.parseValueTypes(p.localIDs, "local", len(f.Params)+len(f.Locals)); err != nil {
+				return err
+			}
+			f.Locals = append(f.Locals, locals...)
+		}
+	}
+
+	switch {
+	case typeUse == nil:
+		// The type is found or added after the whole module is read, as (type) fields can follow the func.
+		p.inlineTypes = append(p.inlineTypes, &inlineType{f: f, t: inline})
+	case hasInline && !inline.equal(typeUse):
+		p.tok = typeUseTok
+		return p.errorf("inline signature %s doesn't match type %s", inline, typeUse)
+	case f.Params == nil:
+		f.Params = typeUseParams(typeUse)
+	}
+
+	body, err := p.parseInstructions()
+	if err != nil {
+		return err
+	}
+	f.Body = append(f.Body, body...)
+	p.module.Funcs = append(p.module.Funcs, f)
+	return p.expectRParen()
+}
+
+// inlineType is the signature of a func without a "(type x)", whose index is resolved after the module is read.
+type inlineType struct {
+	f *Func
+	t *FuncType
+}
+
+// resolveInlineTypes sets the type of each func that only has inline params and results. This uses the first
+// equivalent type, or adds one to the end of the types, like the binary format would.
+//
+// See https://www.w3.org/TR/wasm-core-1/#abbreviations%E2%91%A0
+func (p *parser) resolveInlineTypes() {
+	for _, it := range p.inlineTypes {
+		index := -1
+		for i, t := range p.module.Types {
+			if it.t.equal(t) {
+				index = i
+				break
+			}
+		}
+		if index == -1 {
+			index = len(p.module.Types)
+			p.module.Types = append(p.module.Types, it.t)
+		}
+		it.f.Type = uint32(index)
+	}
+}
+
+// parseTypeUse parses "type x )", where the "(" was already consumed, setting the type of the func.
+func (p *parser) parseTypeUse(f *Func) (*FuncType, error) {
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	tok := p.tok
+	var index uint32
+	if p.tok.Type == TokenID {
+		var ok bool
+		if index, ok = p.typeIDs[p.text()]; !ok {
+			return nil, p.errorf("unknown type %s", p.text())
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if index, err = p.parseU32("type index"); err != nil {
+			return nil, err
+		}
+		if index >= uint32(len(p.module.Types)) {
+			p.tok = tok
+			return nil, p.errorf("unknown type %d", index)
+		}
+	}
+	f.Type = index
+	return p.module.Types[index], p.expectRParen()
+}
+
+// typeUseParams returns the params of a func that only has a "(type x)", which have no ids.
+func typeUseParams(t *FuncType) (ret []*Local) {
+	for _, vt := range t.Params {
+		ret = append(ret, &Local{Type: vt})
+	}
+	return
+}
+
+// parseResults parses "result valtype* )", where the "(" was already consumed, appending the types to results.
+func (p *parser) parseResults(results []wasm.ValueType) ([]wasm.ValueType, error) {
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	for p.tok.Type == TokenKeyword {
+		t, err := p.parseValueType()
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, t)
+	}
+	return results, p.expectRParen()
+}
+
+// parseValueTypes parses "kind id valtype )" or "kind valtype* )" of a param or local, where the "(" was already
+// consumed. An id is added to ids, where the first of the value types has the given index.
+func (p *parser) parseValueTypes(ids map[string]uint32, kind string, index int) ([]*Local, error) {
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if p.tok.Type == TokenID {
+		id, err := p.defineID(ids, kind, uint32(index))
+		if err != nil {
+			return nil, err
+		}
+		t, err := p.parseValueType()
+		if err != nil {
+			return nil, err
+		}
+		return []*Local{{ID: id, Type: t}}, p.expectRParen()
+	}
+	var ret []*Local
+	for p.tok.Type == TokenKeyword {
+		t, err := p.parseValueType()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, &Local{Type: t})
+	}
+	return ret, p.expectRParen()
+}
+
+func appendLocalTypes(types []wasm.ValueType, locals []*Local) []wasm.ValueType {
+	for _, l := range locals {
+		types = append(types, l.Type)
+	}
+	return types
+}
+
+// parseValueType consumes the current keyword as a value type, ex. "i32".
+func (p *parser) parseValueType() (wasm.ValueType, error) {
+	var t wasm.ValueType
+	switch {
+	case p.isKeyword("i32"):
+		t = wasm.ValueTypeI32
+	case p.isKeyword("i64"):
+		t = wasm.ValueTypeI64
+	case p.isKeyword("f32"):
+		t = wasm.ValueTypeF32
+	case p.isKeyword("f64"):
+		t = wasm.ValueTypeF64
+	default:
+		return 0, p.unexpected("a value type")
+	}
+	return t, p.next()
+}
+
+// parseInstructions parses instructions until a ')' or a keyword that ends a block, which isn't consumed.
+func (p *parser) parseInstructions() (ret []*Instruction, err error) {
+	for {
+		var inst *Instruction
+		switch {
+		case p.tok.Type == TokenLParen:
+			if err = p.next(); err != nil {
+				return nil, err
+			}
+			inst, err = p.parseFoldedInstruction()
+		case p.isKeyword("end"), p.tok.Type == TokenRParen:
+			return ret, nil
+		case p.tok.Type == TokenKeyword:
+			inst, err = p.parsePlainInstruction()
+		default:
+			return nil, p.unexpected("an instruction")
+		}
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, inst)
+	}
+}
+
+// parseFoldedInstruction parses "plaininstr foldedinstr* )" or "block label? blocktype? instr* )", where the "(" was
+// already consumed.
+func (p *parser) parseFoldedInstruction() (*Instruction, error) {
+	inst, err := p.parseInstructionKeyword()
+	if err != nil {
+		return nil, err
+	}
+	if inst.OptCode == wasm.OptCodeBlock || inst.OptCode == wasm.OptCodeLoop {
+		if err = p.parseBlock(inst); err != nil {
+			return nil, err
+		}
+		return inst, p.expectRParen()
+	}
+
+	if err = p.parseImmediates(inst); err != nil {
+		return nil, err
+	}
+	for p.tok.Type == TokenLParen {
+		if err = p.next(); err != nil {
+			return nil, err
+		}
+		operand, err := p.parseFoldedInstruction()
+		if err != nil {
+			return nil, err
+		}
+		inst.Operands = append(inst.Operands, operand)
+	}
+	return inst, p.expectRParen()
+}
+
+// parsePlainInstruction parses "plaininstr" or "block label? blocktype? instr* end id?".
+func (p *parser) parsePlainInstruction() (*Instruction, error) {
+	inst, err := p.parseInstructionKeyword()
+	if err != nil {
+		return nil, err
+	}
+	if inst.OptCode != wasm.OptCodeBlock && inst.OptCode != wasm.OptCodeLoop {
+		return inst, p.parseImmediates(inst)
+	}
+
+	if err = p.parseBlock(inst); err != nil {
+		return nil, err
+	}
+	if !p.isKeyword("end") {
+		return nil, p.unexpected("end")
+	}
+	if err = p.next(); err != nil {
+		return nil, err
+	}
+	// The label may be repeated after the end, ex. "block $b ... end $b".
+	if p.tok.Type == TokenID {
+		if id := p.text(); id != inst.Label {
+			return nil, p.errorf("end label %s doesn't match block label %q", id, inst.Label)
+		}
+		return inst, p.next()
+	}
+	return inst, nil
+}
+
+// parseInstructionKeyword consumes the current keyword, returning an Instruction of its opcode. The legacy names of
+// variable instructions are accepted, ex. "get_local".
+func (p *parser) parseInstructionKeyword() (*Instruction, error) {
+	if p.tok.Type != TokenKeyword {
+		return nil, p.unexpected("an instruction")
+	}
+	op, ok := InternKeyword(p.source, p.tok.BeginPos, p.tok.EndPos)
+	if !ok {
+		if op, ok = legacyInstructionKeywords[p.text()]; !ok {
+			return nil, p.errorf("unknown instruction %s", p.text())
+		}
+	}
+	switch op {
+	case wasm.OptCodeIf, wasm.OptCodeElse, wasm.OptCodeEnd, wasm.OptCodeBrTable, wasm.OptCodeCallIndirect,
+		wasm.OptCodeGlobalGet, wasm.OptCodeGlobalSet:
+		return nil, p.errorf("unsupported instruction %s", p.text())
+	}
+	if max := p.opts.MaxInstructions; max > 0 && p.instructionCount == max {
+		return nil, p.errorf("too many instructions: the limit is %d", max)
+	}
+	p.instructionCount++
+	inst := &Instruction{OptCode: op, Line: p.tok.Line, Col: p.tok.Col}
+	return inst, p.next()
+}
+
+// legacyInstructionKeywords are names of instructions from before WebAssembly 1.0 was finalized, which are still
+// common in examples.
+var legacyInstructionKeywords = map[string]wasm.OptCode{
+	"get_local":  wasm.OptCodeLocalGet,
+	"set_local":  wasm.OptCodeLocalSet,
+	"tee_local":  wasm.OptCodeLocalTee,
+	"get_global": wasm.OptCodeGlobalGet,
+	"set_global": wasm.OptCodeGlobalSet,
+}
+
+// parseBlock parses the "label? blocktype? instr*" of a block or loop, stopping before its end.
+func (p *parser) parseBlock(inst *Instruction) (err error) {
+	if inst.Label, err = p.optionalID(); err != nil {
+		return
+	}
+	inst.Immediates = []uint64{blockTypeEmpty}
+	p.labels = append(p.labels, inst.Label)
+	defer func() { p.labels = p.labels[:len(p.labels)-1] }()
+
+	// A "(" is either the result type or the first folded instruction, which is only known after the keyword.
+	if p.tok.Type == TokenLParen {
+		if err = p.next(); err != nil {
+			return
+		}
+		if p.isKeyword("result") {
+			if err = p.next(); err != nil {
+				return
+			}
+			var t wasm.ValueType
+			if t, err = p.parseValueType(); err != nil {
+				return
+			}
+			inst.Immediates[0] = uint64(t)
+			if err = p.expectRParen(); err != nil {
+				return
+			}
+		} else {
+			var first *Instruction
+			if first, err = p.parseFoldedInstruction(); err != nil {
+				return
+			}
+			inst.Body = append(inst.Body, first)
+		}
+	}
+
+	body, err := p.parseInstructions()
+	if err != nil {
+		return
+	}
+	inst.Body = append(inst.Body, body...)
+	return
+}
+
+// parseImmediates parses the immediates of any instruction, except a block or loop.
+func (p *parser) parseImmediates(inst *Instruction) error {
+	switch op := inst.OptCode; op {
+	case wasm.OptCodeLocalGet, wasm.OptCodeLocalSet, wasm.OptCodeLocalTee:
+		if p.tok.Type == TokenID {
+			index, ok := p.localIDs[p.text()]
+			if !ok {
+				return p.errorf("unknown local %s", p.text())
+			}
+			inst.Immediates = []uint64{uint64(index)}
+			return p.next()
+		}
+		index, err := p.parseU32("local index")
+		inst.Immediates = []uint64{uint64(index)}
+		return err
+	case wasm.OptCodeBr, wasm.OptCodeBrIf:
+		if p.tok.Type == TokenID {
+			label := p.text()
+			for i := len(p.labels) - 1; i >= 0; i-- {
+				if p.labels[i] == label {
+					inst.Immediates = []uint64{uint64(len(p.labels) - 1 - i)}
+					return p.next()
+				}
+			}
+			return p.errorf("unknown label %s", label)
+		}
+		depth, err := p.parseU32("label index")
+		inst.Immediates = []uint64{uint64(depth)}
+		return err
+	case wasm.OptCodeCall:
+		inst.Immediates = []uint64{0}
+		return p.parseIndex("func", func(index uint32) { inst.Immediates[0] = uint64(index) })
+	case wasm.OptCodeI32Const, wasm.OptCodeI64Const:
+		bitSize := 32
+		if op == wasm.OptCodeI64Const {
+			bitSize = 64
+		}
+		v, err := p.parseInteger(bitSize)
+		inst.Immediates = []uint64{v}
+		return err
+	case wasm.OptCodeF32Const, wasm.OptCodeF64Const:
+		bitSize := 32
+		if op == wasm.OptCodeF64Const {
+			bitSize = 64
+		}
+		if p.tok.Type != TokenFN && p.tok.Type != TokenUN && p.tok.Type != TokenSN {
+			return p.unexpected("a float")
+		}
+		v, err := parseFNBits(p.source, p.tok.BeginPos, p.tok.EndPos, bitSize)
+		if err != nil {
+			return p.tokenError(err)
+		}
+		inst.Immediates = []uint64{v}
+		return p.next()
+	}
+	if align, ok := naturalAlignments[inst.OptCode]; ok {
+		return p.parseMemArg(inst, align)
+	}
+	return nil
+}
+
+// parseInteger consumes the current TokenUN or TokenSN as an integer of the given bit width. Like the binary format,
+// the integer is uninterpreted, so both the signed and unsigned ranges are allowed, ex. -1 or 0xffff_ffff for 32 bits.
+// The result is sign-extended.
+func (p *parser) parseInteger(bitSize int) (uint64, error) {
+	var v uint64
+	switch p.tok.Type {
+	case TokenUN:
+		u, err := ParseUN(p.source, p.tok.BeginPos, p.tok.EndPos, bitSize)
+		if err != nil {
+			return 0, p.tokenError(err)
+		}
+		v = u << (64 - bitSize)
+		v = uint64(int64(v) >> (64 - bitSize))
+	case TokenSN:
+		s, err := ParseSN(p.source, p.tok.BeginPos, p.tok.EndPos, bitSize)
+		if err != nil {
+			return 0, p.tokenError(err)
+		}
+		v = uint64(s)
+	default:
+		return 0, p.unexpected("an integer")
+	}
+	return v, p.next()
+}
+
+// naturalAlignments are the default alignment, as a power of two, of each memory instruction.
+var naturalAlignments = map[wasm.OptCode]uint64{
+	wasm.OptCodeI32Load: 2, wasm.OptCodeI64Load: 3, wasm.OptCodeF32Load: 2, wasm.OptCodeF64Load: 3,
+	wasm.OptCodeI32Load8s: 0, wasm.OptCodeI32Load8u: 0, wasm.OptCodeI32Load16s: 1, wasm.OptCodeI32Load16u: 1,
+	wasm.OptCodeI64Load8s: 0, wasm.OptCodeI64Load8u: 0, wasm.OptCodeI64Load16s: 1, wasm.OptCodeI64Load16u: 1,
+	wasm.OptCodeI64Load32s: 2, wasm.OptCodeI64Load32u: 2,
+	wasm.OptCodeI32Store: 2, wasm.OptCodeI64Store: 3, wasm.OptCodeF32Store: 2, wasm.OptCodeF64Store: 3,
+	wasm.OptCodeI32Store8: 0, wasm.OptCodeI32Store16: 1,
+	wasm.OptCodeI64Store8: 0, wasm.OptCodeI64Store16: 1, wasm.OptCodeI64Store32: 2,
+}
+
+// parseMemArg parses the optional "offset=N" and "align=N" of a memory instruction, setting its immediates to the
+// alignment and offset.
+//
+// See https://www.w3.org/TR/wasm-core-1/#text-memarg
+func (p *parser) parseMemArg(inst *Instruction, align uint64) error {
+	var offset uint64
+	for _, name := range []string{"offset=", "align="} {
+		if p.tok.Type != TokenKeyword || !strings.HasPrefix(p.text(), name) {
+			continue
+		}
+		begin := p.tok.BeginPos + len(name)
+		v, err := ParseUN(p.source, begin, p.tok.EndPos, 32)
+		if err != nil {
+			return p.tokenError(err)
+		}
+		if name == "offset=" {
+			offset = v
+		} else if bits.OnesCount64(v) != 1 {
+			return p.errorf("alignment must be a power of two: %s", p.text())
+		} else {
+			align = uint64(bits.TrailingZeros64(v))
+		}
+		if err = p.next(); err != nil {
+			return err
+		}
+	}
+	inst.Immediates = []uint64{align, offset}
+	return nil
+}
@@ -0,0 +1,451 @@
+package text
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/tetratelabs/wazero/wasm"
+)
+
+func TestParse_Example(t *testing.T) {
+	i32 := wasm.ValueTypeI32
+	zero := uint32(0)
+	expected := &Module{
+		Memories: []*Memory{{Min: 1, Line: 3, Col: 4}},
+		Types:    []*FuncType{{}},
+		Funcs: []*Func{{
+			ID:     "$main",
+			Locals: []*Local{{Type: i32}, {Type: i32}, {Type: i32}},
+			Body: []*Instruction{
+				{OptCode: wasm.OptCodeLocalSet, Immediates: []uint64{0}, Line: 5, Col: 6, Operands: []*Instruction{
+					{OptCode: wasm.OptCodeI32Const, Immediates: []uint64{0}, Line: 5, Col: 19},
+				}},
+				{OptCode: wasm.OptCodeLocalSet, Immediates: []uint64{1}, Line: 6, Col: 6, Operands: []*Instruction{
+					{OptCode: wasm.OptCodeI32Const, Immediates: []uint64{1}, Line: 6, Col: 19},
+				}},
+				{OptCode: wasm.OptCodeLocalSet, Immediates: []uint64{2}, Line: 7, Col: 6, Operands: []*Instruction{
+					{OptCode: wasm.OptCodeI32Const, Immediates: []uint64{10}, Line: 7, Col: 19},
+				}},
+				{OptCode: wasm.OptCodeLoop, Immediates: []uint64{blockTypeEmpty}, Line: 8, Col: 6, Body: []*Instruction{
+					{OptCode: wasm.OptCodeLocalSet, Immediates: []uint64{1}, Line: 9, Col: 8, Operands: []*Instruction{
+						{OptCode: wasm.OptCodeI32add, Line: 9, Col: 21, Operands: []*Instruction{
+							{OptCode: wasm.OptCodeLocalGet, Immediates: []uint64{0}, Line: 9, Col: 30},
+							{OptCode: wasm.OptCodeLocalTee, Immediates: []uint64{0}, Line: 9, Col: 44, Operands: []*Instruction{
+								{OptCode: wasm.OptCodeLocalGet, Immediates: []uint64{1}, Line: 9, Col: 57},
+							}},
+						}},
+					}},
+					{OptCode: wasm.OptCodeBrIf, Immediates: []uint64{0}, Line: 10, Col: 8, Operands: []*Instruction{
+						{OptCode: wasm.OptCodeLocalTee, Immediates: []uint64{2}, Line: 10, Col: 17, Operands: []*Instruction{
+							{OptCode: wasm.OptCodeI32sub, Line: 10, Col: 30, Operands: []*Instruction{
+								{OptCode: wasm.OptCodeLocalGet, Immediates: []uint64{2}, Line: 10, Col: 39},
+								{OptCode: wasm.OptCodeI32Const, Immediates: []uint64{1}, Line: 10, Col: 53},
+							}},
+						}},
+					}},
+				}},
+				{OptCode: wasm.OptCodeI32Store, Immediates: []uint64{2, 0}, Line: 12, Col: 6, Operands: []*Instruction{
+					{OptCode: wasm.OptCodeI32Const, Immediates: []uint64{0}, Line: 12, Col: 17},
+					{OptCode: wasm.OptCodeLocalGet, Immediates: []uint64{0}, Line: 12, Col: 31},
+				}},
+			},
+			Line: 4, Col: 4,
+		}},
+		Start: &zero,
+	}
+
+	actual, err := Parse(exampleWat)
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+}
+
+func TestParse(t *testing.T) {
+	one, two := uint32(1), uint32(2)
+	tests := []struct {
+		name, input string
+		expected    *Module
+	}{
+		{
+			name:     "empty",
+			input:    "(module)",
+			expected: &Module{},
+		},
+		{
+			name:     "module id",
+			input:    "(module $m)",
+			expected: &Module{ID: "$m"},
+		},
+		{
+			name:  "memory with max",
+			input: "(module (memory $mem 1 2))",
+			expected: &Module{
+				Memories: []*Memory{{ID: "$mem", Min: 1, Max: &two, Line: 1, Col: 10}},
+			},
+		},
+		{
+			name:  "start before its func",
+			input: "(module (start $b) (func $a) (func $b))",
+			expected: &Module{
+				Types: []*FuncType{{}},
+				Funcs: []*Func{{ID: "$a", Line: 1, Col: 21}, {ID: "$b", Line: 1, Col: 31}},
+				Start: &one,
+			},
+		},
+		{
+			name:  "same id for a func and memory",
+			input: "(module (func $x) (memory $x 1))",
+			expected: &Module{
+				Memories: []*Memory{{ID: "$x", Min: 1, Line: 1, Col: 20}},
+				Types:    []*FuncType{{}},
+				Funcs:    []*Func{{ID: "$x", Line: 1, Col: 10}},
+			},
+		},
+		{
+			name:  "exports",
+			input: `(module (export "f" (func $f)) (func $f) (memory 1) (export "\u{263a}" (memory 0)))`,
+			expected: &Module{
+				Types:    []*FuncType{{}},
+				Funcs:    []*Func{{ID: "$f", Line: 1, Col: 33}},
+				Memories: []*Memory{{Min: 1, Line: 1, Col: 43}},
+				Exports: []*Export{
+					{Name: "f", Kind: wasm.ExportKindFunction, Line: 1, Col: 10},
+					{Name: "☺", Kind: wasm.ExportKindMemory, Line: 1, Col: 54},
+				},
+			},
+		},
+		{
+			name:  "data",
+			input: `(module (memory $m 1) (data $m (offset (i32.const 8)) "a" "\62\63"))`,
+			expected: &Module{
+				Memories: []*Memory{{ID: "$m", Min: 1, Line: 1, Col: 10}},
+				Data: []*Data{{
+					Offset: []*Instruction{{OptCode: wasm.OptCodeI32Const, Immediates: []uint64{8}, Line: 1, Col: 41}},
+					Init:   []byte("abc"),
+					Line:   1, Col: 24,
+				}},
+			},
+		},
+		{
+			name:  "data isn't UTF-8",
+			input: `(module (memory 1) (data (i32.const 0) "\ff"))`,
+			expected: &Module{
+				Memories: []*Memory{{Min: 1, Line: 1, Col: 10}},
+				Data: []*Data{{
+					Offset: []*Instruction{{OptCode: wasm.OptCodeI32Const, Immediates: []uint64{0}, Line: 1, Col: 27}},
+					Init:   []byte{0xff},
+					Line:   1, Col: 21,
+				}},
+			},
+		},
+		{
+			name:  "named locals and plain instructions",
+			input: "(module (func (local $a i32) (local i64 f32) (local $d f64) local.get $d local.set $a call 0))",
+			expected: &Module{
+				Types: []*FuncType{{}},
+				Funcs: []*Func{{
+					Locals: []*Local{{ID: "$a", Type: wasm.ValueTypeI32}, {Type: wasm.ValueTypeI64}, {Type: wasm.ValueTypeF32}, {ID: "$d", Type: wasm.ValueTypeF64}},
+					Body: []*Instruction{
+						{OptCode: wasm.OptCodeLocalGet, Immediates: []uint64{3}, Line: 1, Col: 61},
+						{OptCode: wasm.OptCodeLocalSet, Immediates: []uint64{0}, Line: 1, Col: 74},
+						{OptCode: wasm.OptCodeCall, Immediates: []uint64{0}, Line: 1, Col: 87},
+					},
+					Line: 1, Col: 10,
+				}},
+			},
+		},
+		{
+			name:  "constants",
+			input: "(module (func i32.const 0xffff_ffff i32.const -1 i64.const -0x8000_0000_0000_0000 f32.const -nan:0x200000 f64.const 1))",
+			expected: &Module{
+				Types: []*FuncType{{}},
+				Funcs: []*Func{{
+					Body: []*Instruction{
+						{OptCode: wasm.OptCodeI32Const, Immediates: []uint64{0xffff_ffff_ffff_ffff}, Line: 1, Col: 15},
+						{OptCode: wasm.OptCodeI32Const, Immediates: []uint64{0xffff_ffff_ffff_ffff}, Line: 1, Col: 37},
+						{OptCode: wasm.OptCodeI64Const, Immediates: []uint64{0x8000_0000_0000_0000}, Line: 1, Col: 50},
+						{OptCode: wasm.OptCodeF32Const, Immediates: []uint64{0xffa0_0000}, Line: 1, Col: 83},
+						{OptCode: wasm.OptCodeF64Const, Immediates: []uint64{0x3ff0_0000_0000_0000}, Line: 1, Col: 107},
+					},
+					Line: 1, Col: 10,
+				}},
+			},
+		},
+		{
+			name:  "memarg",
+			input: "(module (func i64.load offset=8 align=4 i32.store8 memory.size))",
+			expected: &Module{
+				Types: []*FuncType{{}},
+				Funcs: []*Func{{
+					Body: []*Instruction{
+						{OptCode: wasm.OptCodeI64Load, Immediates: []uint64{2, 8}, Line: 1, Col: 15},
+						{OptCode: wasm.OptCodeI32Store8, Immediates: []uint64{0, 0}, Line: 1, Col: 41},
+						{OptCode: wasm.OptCodeMemorySize, Line: 1, Col: 52},
+					},
+					Line: 1, Col: 10,
+				}},
+			},
+		},
+		{
+			name:  "labels",
+			input: "(module (func block $outer (result i32) loop $inner br $outer br_if $inner br 1 end $inner end (block (br 0))))",
+			expected: &Module{
+				Types: []*FuncType{{}},
+				Funcs: []*Func{{
+					Body: []*Instruction{
+						{OptCode: wasm.OptCodeBlock, Label: "$outer", Immediates: []uint64{uint64(wasm.ValueTypeI32)}, Line: 1, Col: 15, Body: []*Instruction{
+							{OptCode: wasm.OptCodeLoop, Label: "$inner", Immediates: []uint64{blockTypeEmpty}, Line: 1, Col: 41, Body: []*Instruction{
+								{OptCode: wasm.OptCodeBr, Immediates: []uint64{1}, Line: 1, Col: 53},
+								{OptCode: wasm.OptCodeBrIf, Immediates: []uint64{0}, Line: 1, Col: 63},
+								{OptCode: wasm.OptCodeBr, Immediates: []uint64{1}, Line: 1, Col: 76},
+							}},
+						}},
+						{OptCode: wasm.OptCodeBlock, Immediates: []uint64{blockTypeEmpty}, Line: 1, Col: 97, Body: []*Instruction{
+							{OptCode: wasm.OptCodeBr, Immediates: []uint64{0}, Line: 1, Col: 104},
+						}},
+					},
+					Line: 1, Col: 10,
+				}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := Parse([]byte(tc.input))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestParse_Types(t *testing.T) {
+	i32, i64 := wasm.ValueTypeI32, wasm.ValueTypeI64
+	tests := []struct {
+		name, input string
+		expected    *Module
+	}{
+		{
+			name:  "type field",
+			input: "(module (type $t (func (param i32 i32) (result i32))) (type (func)))",
+			expected: &Module{Types: []*FuncType{
+				{ID: "$t", Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}},
+				{},
+			}},
+		},
+		{
+			name:  "named and anonymous params",
+			input: "(module (func $f (param $a i32) (param i64 i32) (result i64) (local $d i32) local.get $a local.get $d))",
+			expected: &Module{
+				Types: []*FuncType{{Params: []wasm.ValueType{i32, i64, i32}, Results: []wasm.ValueType{i64}}},
+				Funcs: []*Func{{
+					ID:     "$f",
+					Params: []*Local{{ID: "$a", Type: i32}, {Type: i64}, {Type: i32}},
+					Locals: []*Local{{ID: "$d", Type: i32}},
+					Body: []*Instruction{
+						{OptCode: wasm.OptCodeLocalGet, Immediates: []uint64{0}, Line: 1, Col: 77},
+						{OptCode: wasm.OptCodeLocalGet, Immediates: []uint64{3}, Line: 1, Col: 90},
+					},
+					Line: 1, Col: 10,
+				}},
+			},
+		},
+		{
+			name: "shared type used by two funcs",
+			input: `(module
+  (type $add (func (param i32 i32) (result i32)))
+  (type $nop (func))
+  (func $a (type $add) (local.get 1))
+  (func $b (type $add) (param $x i32) (param $y i32) (result i32) (local.get $x))
+  (func $c (param i32 i32) (result i32) (local.get 0))
+)`,
+			expected: &Module{
+				Types: []*FuncType{
+					{ID: "$add", Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}},
+					{ID: "$nop"},
+				},
+				Funcs: []*Func{
+					{
+						ID: "$a", Params: []*Local{{Type: i32}, {Type: i32}},
+						Body: []*Instruction{{OptCode: wasm.OptCodeLocalGet, Immediates: []uint64{1}, Line: 4, Col: 25}},
+						Line: 4, Col: 4,
+					},
+					{
+						ID: "$b", Params: []*Local{{ID: "$x", Type: i32}, {ID: "$y", Type: i32}},
+						Body: []*Instruction{{OptCode: wasm.OptCodeLocalGet, Immediates: []uint64{0}, Line: 5, Col: 68}},
+						Line: 5, Col: 4,
+					},
+					{
+						// The inline signature is the same as $add, so the type is shared.
+						ID: "$c", Params: []*Local{{Type: i32}, {Type: i32}},
+						Body: []*Instruction{{OptCode: wasm.OptCodeLocalGet, Immediates: []uint64{0}, Line: 6, Col: 42}},
+						Line: 6, Col: 4,
+					},
+				},
+			},
+		},
+		{
+			name:  "inline signature added after type fields",
+			input: "(module (func (result i64)) (type (func)) (func (result i64)))",
+			expected: &Module{
+				Types: []*FuncType{{}, {Results: []wasm.ValueType{i64}}},
+				Funcs: []*Func{{Type: 1, Line: 1, Col: 10}, {Type: 1, Line: 1, Col: 44}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := Parse([]byte(tc.input))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name, input string
+		expectedErr string
+	}{
+		{name: "empty", input: "", expectedErr: "1:1 expected '(', but got EOF"},
+		{name: "not a module", input: "(func)", expectedErr: "1:2 expected module, but got keyword func"},
+		{name: "unclosed module", input: "(module", expectedErr: "1:8 expected ')', but got EOF"},
+		{name: "after module", input: "(module) (module)", expectedErr: "1:10 expected EOF, but got '('"},
+		{name: "unsupported field", input: "(module (table 1 funcref))", expectedErr: "1:10 unsupported module field table"},
+		{name: "lex error", input: "(module ,)", expectedErr: "1:9 unexpected character ','"},
+		{name: "memory without min", input: "(module (memory))", expectedErr: "1:16 expected memory min, but got ')'"},
+		{name: "memory out of range", input: "(module (memory 0x1_0000_0000))", expectedErr: "1:17 constant out of range: 0x1_0000_0000"},
+		{name: "duplicate func", input: "(module (func $x) (func $x))", expectedErr: "1:25 duplicate func $x"},
+		{name: "duplicate local", input: "(module (func (local $x i32) (local $x i32)))", expectedErr: "1:37 duplicate local $x"},
+		{name: "duplicate start", input: "(module (func) (start 0) (start 0))", expectedErr: "1:27 duplicate start"},
+		{name: "unknown func", input: "(module (start $main))", expectedErr: "1:16 unknown func $main"},
+		{name: "unknown memory", input: `(module (data $m (i32.const 0) ""))`, expectedErr: "1:15 unknown memory $m"},
+		{name: "unknown local", input: "(module (func local.get $x))", expectedErr: "1:25 unknown local $x"},
+		{name: "unknown label", input: "(module (func (block $a) br $a))", expectedErr: "1:29 unknown label $a"},
+		{name: "unknown instruction", input: "(module (func i32.foo))", expectedErr: "1:15 unknown instruction i32.foo"},
+		{name: "unsupported instruction", input: "(module (func global.get 0))", expectedErr: "1:15 unsupported instruction global.get"},
+		{name: "local after instruction", input: "(module (func nop (local i32)))", expectedErr: "1:20 unknown instruction local"},
+		{name: "invalid value type", input: "(module (func (local i8)))", expectedErr: "1:22 expected a value type, but got keyword i8"},
+		{name: "i32 out of range", input: "(module (func i32.const 0x1_0000_0000))", expectedErr: "1:25 constant out of range: 0x1_0000_0000"},
+		{name: "float expected", input: "(module (func f32.const $x))", expectedErr: "1:25 expected a float, but got id $x"},
+		{name: "alignment", input: "(module (func i32.load align=3))", expectedErr: "1:24 alignment must be a power of two: align=3"},
+		{name: "block without end", input: "(module (func block nop))", expectedErr: "1:24 expected end, but got ')'"},
+		{name: "end label mismatch", input: "(module (func block $a end $b))", expectedErr: `1:28 end label $b doesn't match block label "$a"`},
+		{name: "type without func", input: "(module (type (param i32)))", expectedErr: "1:16 expected func, but got keyword param"},
+		{name: "type param after result", input: "(module (type (func (result i32) (param i32))))", expectedErr: "1:35 expected param or result, but got keyword param"},
+		{name: "duplicate type", input: "(module (type $t (func)) (type $t (func)))", expectedErr: "1:32 duplicate type $t"},
+		{name: "unknown type", input: "(module (func (type $t)))", expectedErr: "1:21 unknown type $t"},
+		{name: "unknown type index", input: "(module (type (func)) (func (type 1)))", expectedErr: "1:35 unknown type 1"},
+		{name: "type defined after use", input: "(module (func (type $t)) (type $t (func)))", expectedErr: "1:21 unknown type $t"},
+		{name: "inline signature mismatch", input: "(module (type (func)) (func (type 0) (param i32)))", expectedErr: "1:30 inline signature [i32]->[] doesn't match type []->[]"},
+		{name: "param after result", input: "(module (func (result i32) (param i32)))", expectedErr: "1:29 unexpected param"},
+		{name: "param after local", input: "(module (func (local i32) (param i32)))", expectedErr: "1:28 unexpected param"},
+		{name: "duplicate type use", input: "(module (type (func)) (func (type 0) (type 0)))", expectedErr: "1:39 unexpected type"},
+		{name: "duplicate param", input: "(module (func (param $x i32) (local $x i32)))", expectedErr: "1:37 duplicate local $x"},
+		{name: "export without name", input: "(module (export (func 0)))", expectedErr: "1:17 expected export name, but got '('"},
+		{name: "export without description", input: `(module (export "f"))`, expectedErr: "1:20 expected export description, but got ')'"},
+		{name: "unsupported export", input: `(module (export "t" (table 0)))`, expectedErr: "1:22 unsupported export table"},
+		{name: "export name isn't UTF-8", input: `(module (func) (export "\ff" (func 0)))`, expectedErr: "1:24 malformed UTF-8 encoding"},
+		{name: "duplicate export", input: `(module (func) (export "f" (func 0)) (export "f" (func 0)))`, expectedErr: `1:46 duplicate export "f"`},
+		{name: "unknown export func", input: `(module (export "f" (func $f)))`, expectedErr: "1:27 unknown func $f"},
+		{name: "invalid string", input: `(module (data (i32.const 0) "\x"))`, expectedErr: `1:29 unknown escape \x`},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse([]byte(tc.input))
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestParse_DataStrings(t *testing.T) {
+	concatenated, err := Parse([]byte(`(module (memory 1) (data (i32.const 0) "ab" "cd"))`))
+	require.NoError(t, err)
+	single, err := Parse([]byte(`(module (memory 1) (data (i32.const 0) "abcd"))`))
+	require.NoError(t, err)
+	require.Equal(t, []byte("abcd"), concatenated.Data[0].Init)
+	require.Equal(t, single.Data[0].Init, concatenated.Data[0].Init)
+}
+
+func TestParseWithOptions(t *testing.T) {
+	source := []byte(`(module (func nop) (func (drop (i32.const 1))) (export "a" (func 0)) (export "b" (func 1)))`)
+	m, err := ParseWithOptions(source, ParseOptions{MaxFuncs: 2, MaxExports: 2, MaxInstructions: 3})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(m.Funcs))
+}
+
+func TestParseWithOptions_Errors(t *testing.T) {
+	tests := []struct {
+		name, input string
+		opts        ParseOptions
+		expectedErr string
+	}{
+		{
+			name:        "too many funcs",
+			input:       "(module (func) (func) (func))",
+			opts:        ParseOptions{MaxFuncs: 2},
+			expectedErr: "1:24 too many funcs: the limit is 2",
+		},
+		{
+			name:        "too many exports",
+			input:       `(module (func) (export "a" (func 0)) (export "b" (func 0)))`,
+			opts:        ParseOptions{MaxExports: 1},
+			expectedErr: "1:39 too many exports: the limit is 1",
+		},
+		{
+			name:        "too many instructions across funcs",
+			input:       "(module (func nop nop) (func nop))",
+			opts:        ParseOptions{MaxInstructions: 2},
+			expectedErr: "1:30 too many instructions: the limit is 2",
+		},
+		{
+			name:        "too many folded instructions",
+			input:       "(module (func (block (block (block)))))",
+			opts:        ParseOptions{MaxInstructions: 2},
+			expectedErr: "1:30 too many instructions: the limit is 2",
+		},
+		{
+			name:        "data offset counts",
+			input:       `(module (memory 1) (func nop) (data (i32.const 0) ""))`,
+			opts:        ParseOptions{MaxInstructions: 1},
+			expectedErr: "1:38 too many instructions: the limit is 1",
+		},
+		{
+			name:        "lex options",
+			input:       "(module (; (; ;) ;))",
+			opts:        ParseOptions{LexOptions: LexOptions{MaxBlockCommentDepth: 1}},
+			expectedErr: "1:12 block comment nested too deeply",
+		},
+		{name: "negative MaxFuncs", input: "(module)", opts: ParseOptions{MaxFuncs: -1}, expectedErr: "invalid MaxFuncs -1"},
+		{name: "negative MaxExports", input: "(module)", opts: ParseOptions{MaxExports: -1}, expectedErr: "invalid MaxExports -1"},
+		{name: "negative MaxInstructions", input: "(module)", opts: ParseOptions{MaxInstructions: -1}, expectedErr: "invalid MaxInstructions -1"},
+		{
+			name:        "negative MaxBlockCommentDepth",
+			input:       "(module)",
+			opts:        ParseOptions{LexOptions: LexOptions{MaxBlockCommentDepth: -1}},
+			expectedErr: "invalid MaxBlockCommentDepth -1",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseWithOptions([]byte(tc.input), tc.opts)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestParseError(t *testing.T) {
+	_, err := Parse([]byte("(module\n  (start $main))"))
+	require.Equal(t, &ParseError{Line: 2, Col: 10, Pos: 17, Message: "unknown func $main"}, err)
+}
@@ -0,0 +1,51 @@
+package text
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// LineIndex maps byte offsets in a source back to the line and column the lexer would report for them. This allows
+// errors to carry only a byte offset, ex. from ParseUN, and still be reported as "line:col".
+type LineIndex struct {
+	source []byte
+	// lineStarts are the byte offsets of the first byte of each line, so lineStarts[0] is the first line.
+	lineStarts []int
+}
+
+// NewLineIndex scans the source once, recording where each line begins. Only '\n' begins a line, as in lex.
+func NewLineIndex(source []byte) *LineIndex {
+	lineStarts := []int{bomLength(source)} // a BOM isn't a column, so the first line starts after it
+	for p, b := range source {
+		if b == '\n' {
+			lineStarts = append(lineStarts, p+1)
+		}
+	}
+	return &LineIndex{source: source, lineStarts: lineStarts}
+}
+
+// LineCol returns the 1-based line and column of the byte offset in the source. Like lex, columns count characters,
+// not bytes. An offset inside a multi-byte character returns the column of that character.
+//
+// An offset past the end of the source returns the position after the last character, which is where lex reports
+// TokenEOF.
+func (i *LineIndex) LineCol(offset int) (line, col int) {
+	if offset > len(i.source) {
+		offset = len(i.source)
+	}
+	// Find the last line that starts at or before the offset.
+	n := sort.Search(len(i.lineStarts), func(n int) bool { return i.lineStarts[n] > offset }) - 1
+	if n < 0 { // inside the BOM
+		return 1, 1
+	}
+	lineStart := i.lineStarts[n]
+	col = 1
+	for p := lineStart; p < offset; {
+		_, size := utf8.DecodeRune(i.source[p:])
+		p += size
+		if p <= offset {
+			col++
+		}
+	}
+	return n + 1, col
+}
@@ -0,0 +1,47 @@
+package text
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLineIndex_LineCol(t *testing.T) {
+	index := NewLineIndex(exampleWat)
+	tokens := lexAllTokens(t, func(parser ParseToken) error { return lex(exampleWat, LexOptions{}, parser) })
+	for _, tok := range tokens {
+		line, col := index.LineCol(tok.pos)
+		require.Equal(t, [2]int{tok.line, tok.col}, [2]int{line, col}, tok.String())
+	}
+}
+
+func TestLineIndex_LineCol_Characters(t *testing.T) {
+	source := []byte("\xef\xbb\xbf☺a\r\n\nb")
+	tests := []struct {
+		name                      string
+		offset                    int
+		expectedLine, expectedCol int
+	}{
+		{name: "inside BOM", offset: 1, expectedLine: 1, expectedCol: 1},
+		{name: "after BOM", offset: 3, expectedLine: 1, expectedCol: 1},
+		{name: "inside multi-byte character", offset: 5, expectedLine: 1, expectedCol: 1},
+		{name: "after multi-byte character", offset: 6, expectedLine: 1, expectedCol: 2},
+		{name: "carriage return", offset: 7, expectedLine: 1, expectedCol: 3},
+		{name: "newline", offset: 8, expectedLine: 1, expectedCol: 4},
+		{name: "empty line", offset: 9, expectedLine: 2, expectedCol: 1},
+		{name: "last line", offset: 10, expectedLine: 3, expectedCol: 1},
+		{name: "end", offset: 11, expectedLine: 3, expectedCol: 2},
+		{name: "past end", offset: 100, expectedLine: 3, expectedCol: 2},
+	}
+
+	index := NewLineIndex(source)
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			line, col := index.LineCol(tc.offset)
+			require.Equal(t, tc.expectedLine, line)
+			require.Equal(t, tc.expectedCol, col)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package text
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// DecodeString returns the bytes encoded by the TokenString at source[beginPos:endPos], which includes the enclosing
+// double quotes. For example, "☺\n", "\u{263a}\u{0a}" and "\e2\98\ba\0a" all decode to 0xe2 0x98 0xba 0x0a.
+//
+// The result never shares memory with the source, and may be any bytes, as a string in a data field is. Errors include
+// the byte offset of the invalid escape.
+//
+// See https://www.w3.org/TR/wasm-core-1/#strings%E2%91%A0
+func DecodeString(source []byte, beginPos, endPos int) ([]byte, error) {
+	return decodeStringBytes(source, beginPos, endPos)
+}
+
+// decodeStringUTF8 is like decodeStringBytes, except the result must be valid UTF-8, as is required of a name, ex. of an
+// export. Hexadecimal escapes can still encode a name, ex. "\e2\98\ba", as long as the bytes are a valid encoding.
+//
+// See https://www.w3.org/TR/wasm-core-1/#names%E2%91%A2
+func decodeStringUTF8(source []byte, beginPos, endPos int) (string, error) {
+	b, err := decodeStringBytes(source, beginPos, endPos)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(b) {
+		return "", fmt.Errorf("byte offset %d: malformed UTF-8 encoding", beginPos)
+	}
+	return string(b), nil
+}
+
+// decodeStringBytes decodes the string to any bytes. See DecodeString.
+func decodeStringBytes(source []byte, beginPos, endPos int) ([]byte, error) {
+	if beginPos < 0 || endPos > len(source) || endPos-beginPos < 2 || source[beginPos] != '"' || source[endPos-1] != '"' {
+		return nil, fmt.Errorf("byte offset %d: expected a string enclosed in double quotes", beginPos)
+	}
+
+	ret := make([]byte, 0, endPos-beginPos-2)
+	for p := beginPos + 1; p < endPos-1; p++ {
+		if source[p] != '\\' {
+			ret = append(ret, source[p])
+			continue
+		}
+
+		escapePos := p
+		if p == endPos-2 {
+			// The backslash would otherwise escape the closing quote, ex. "\".
+			return nil, fmt.Errorf("byte offset %d: incomplete escape at the end of the string", escapePos)
+		}
+		p++
+		switch b := source[p]; b {
+		case 't':
+			ret = append(ret, '\t')
+		case 'n':
+			ret = append(ret, '\n')
+		case 'r':
+			ret = append(ret, '\r')
+		case '"', '\'', '\\':
+			ret = append(ret, b)
+		case 'u':
+			// The code point is hexadecimal, with optional underscores between digits, ex. "\u{1_F600}".
+			end := p + 1
+			for end < endPos-1 && source[end] != '}' {
+				end++
+			}
+			if end == endPos-1 {
+				return nil, fmt.Errorf("byte offset %d: expected '}' to end escape %s", escapePos, source[escapePos:end])
+			}
+			r, ok := decodeCodePoint(source[p+1 : end])
+			if !ok {
+				return nil, fmt.Errorf("byte offset %d: invalid escape %s", escapePos, source[escapePos:end+1])
+			}
+			var buf [utf8.UTFMax]byte
+			ret = append(ret, buf[:utf8.EncodeRune(buf[:], r)]...)
+			p = end
+		default:
+			if p+1 < endPos-1 && isHexDigit(b) && isHexDigit(source[p+1]) {
+				ret = append(ret, hexValue(b)<<4|hexValue(source[p+1]))
+				p++
+				continue
+			}
+			r, _ := utf8.DecodeRune(source[p : endPos-1])
+			return nil, fmt.Errorf("byte offset %d: unknown escape \\%c", escapePos, r)
+		}
+	}
+	return ret, nil
+}
+
+// decodeCodePoint decodes the text "{hexnum" to a Unicode scalar value, which excludes surrogates.
+func decodeCodePoint(text []byte) (rune, bool) {
+	if len(text) < 2 || text[0] != '{' || text[1] == '_' || text[len(text)-1] == '_' {
+		return 0, false
+	}
+	var r rune
+	for i := 1; i < len(text); i++ {
+		b := text[i]
+		if b == '_' {
+			if text[i-1] == '_' {
+				return 0, false
+			}
+			continue
+		}
+		if !isHexDigit(b) {
+			return 0, false
+		}
+		if r = r<<4 | rune(hexValue(b)); r > utf8.MaxRune {
+			return 0, false
+		}
+	}
+	if r >= 0xd800 && r < 0xe000 {
+		return 0, false
+	}
+	return r, true
+}
+
+// hexValue returns the value of the hexadecimal digit.
+func hexValue(b byte) byte {
+	switch {
+	case b >= 'a':
+		return b - 'a' + 10
+	case b >= 'A':
+		return b - 'A' + 10
+	}
+	return b - '0'
+}
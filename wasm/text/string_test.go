@@ -0,0 +1,101 @@
+package text
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeString(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []byte
+	}{
+		{name: "empty", input: `""`, expected: []byte{}},
+		{name: "ASCII", input: `"hello"`, expected: []byte("hello")},
+		{name: "UTF-8 and escaped newline", input: `"☺\n"`, expected: []byte{0xe2, 0x98, 0xba, 0x0a}},
+		{name: "code points", input: `"\u{263a}\u{0a}"`, expected: []byte{0xe2, 0x98, 0xba, 0x0a}},
+		{name: "hex bytes", input: `"\e2\98\ba\0a"`, expected: []byte{0xe2, 0x98, 0xba, 0x0a}},
+		{name: "upper case hex bytes", input: `"\E2\98\BA\0A"`, expected: []byte{0xe2, 0x98, 0xba, 0x0a}},
+		{name: "simple escapes", input: `"\t\n\r\"\'\\"`, expected: []byte("\t\n\r\"'\\")},
+		{name: "code point with underscore", input: `"\u{1_F600}"`, expected: []byte("😀")},
+		{name: "largest code point", input: `"\u{10FFFF}"`, expected: []byte("\U0010FFFF")},
+		{name: "hex byte that isn't UTF-8", input: `"\ff"`, expected: []byte{0xff}},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			source := []byte("(data " + tc.input + ")")
+			actual, err := DecodeString(source, 6, 6+len(tc.input))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestDecodeString_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expectedErr string
+	}{
+		{name: "not a string", input: "abc", expectedErr: "byte offset 0: expected a string enclosed in double quotes"},
+		{name: "one quote", input: `"`, expectedErr: "byte offset 0: expected a string enclosed in double quotes"},
+		{name: "escaped closing quote", input: `"\"`, expectedErr: "byte offset 1: incomplete escape at the end of the string"},
+		{name: "escape at end", input: `"a\"`, expectedErr: "byte offset 2: incomplete escape at the end of the string"},
+		{name: "unknown escape", input: `"a\x"`, expectedErr: `byte offset 2: unknown escape \x`},
+		{name: "unknown unicode escape", input: `"\☺"`, expectedErr: `byte offset 1: unknown escape \☺`},
+		{name: "one hex digit", input: `"\e"`, expectedErr: `byte offset 1: unknown escape \e`},
+		{name: "unicode without braces", input: `"\u263a"`, expectedErr: `byte offset 1: expected '}' to end escape \u263a`},
+		{name: "unicode without open brace", input: `"\u263a}"`, expectedErr: `byte offset 1: invalid escape \u263a}`},
+		{name: "unicode without end", input: `"\u{263a"`, expectedErr: `byte offset 1: expected '}' to end escape \u{263a`},
+		{name: "unicode without digits", input: `"\u{}"`, expectedErr: `byte offset 1: invalid escape \u{}`},
+		{name: "unicode with non-hex", input: `"\u{x}"`, expectedErr: `byte offset 1: invalid escape \u{x}`},
+		{name: "unicode with leading underscore", input: `"\u{_1}"`, expectedErr: `byte offset 1: invalid escape \u{_1}`},
+		{name: "unicode with double underscore", input: `"\u{1__1}"`, expectedErr: `byte offset 1: invalid escape \u{1__1}`},
+		{name: "surrogate", input: `"\u{d800}"`, expectedErr: `byte offset 1: invalid escape \u{d800}`},
+		{name: "too large", input: `"\u{110000}"`, expectedErr: `byte offset 1: invalid escape \u{110000}`},
+		{name: "overflow", input: `"\u{1000000000000000001}"`, expectedErr: `byte offset 1: invalid escape \u{1000000000000000001}`},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := DecodeString([]byte(tc.input), 0, len(tc.input))
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestDecodeStringUTF8(t *testing.T) {
+	name, err := decodeStringUTF8([]byte(`"\e2\98\ba"`), 0, 11)
+	require.NoError(t, err)
+	require.Equal(t, "☺", name)
+
+	// Any bytes are allowed in data, but not in a name.
+	input := []byte(`"\ff"`)
+	b, err := decodeStringBytes(input, 0, len(input))
+	require.NoError(t, err)
+	require.Equal(t, []byte{0xff}, b)
+	_, err = decodeStringUTF8(input, 0, len(input))
+	require.EqualError(t, err, "byte offset 0: malformed UTF-8 encoding")
+}
+
+func TestDecodeString_LexedTokens(t *testing.T) {
+	source := []byte(`(data (i32.const 0) "\u{263a}" "\e2\98\ba")`)
+	var decoded [][]byte
+	err := lex(source, LexOptions{}, func(source []byte, tok TokenType, beginLine, beginCol, beginPos, endPos int) error {
+		if tok == TokenString {
+			b, err := DecodeString(source, beginPos, endPos)
+			decoded = append(decoded, b)
+			return err
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("☺"), []byte("☺")}, decoded)
+}
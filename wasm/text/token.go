@@ -0,0 +1,90 @@
+package text
+
+import "fmt"
+
+// TokenType is a classification of the WebAssembly text format tokens.
+// See https://www.w3.org/TR/wasm-core-1/#tokens%E2%91%A0
+type TokenType byte
+
+const (
+	// TokenKeyword is a sequence of idchar characters that begins with a lowercase letter, ex. "module" or
+	// "i32.const". Keywords are how the text format names module fields and instructions.
+	//
+	// See https://www.w3.org/TR/wasm-core-1/#keywords%E2%91%A0
+	TokenKeyword TokenType = iota
+
+	// TokenUN is an unsigned integer in decimal or hexadecimal notation, optionally separated by underscores, ex.
+	// "10", "1_0", "0x0a" or "0x0_A". The value is not range-checked by the lexer: that depends on the context, such
+	// as whether the number is an i32 or i64 immediate.
+	//
+	// See https://www.w3.org/TR/wasm-core-1/#integers%E2%91%A6
+	TokenUN
+
+	// TokenSN is a signed integer, which is a TokenUN with a leading '+' or '-', ex. "+10", "-0x1F" or "+1_0".
+	//
+	// See https://www.w3.org/TR/wasm-core-1/#integers%E2%91%A6
+	TokenSN
+
+	// TokenFN is a floating point number in decimal or hexadecimal notation, optionally signed, or one of the special
+	// values infinity and "not a number" (NaN), ex. "1.e10", "0x1.fff_fffp+1_023", "+inf", "+nan" or
+	// "-nan:0xfffffffffffff".
+	//
+	// Note: A TokenUN or TokenSN is also valid where a float is expected, ex. "10" in "(f32.const 10)".
+	//
+	// See https://www.w3.org/TR/wasm-core-1/#floating-point%E2%91%A6
+	TokenFN
+
+	// TokenString is a sequence of characters enclosed in double quotes, which can encode arbitrary bytes via escapes,
+	// ex. "" or "\n". The following all encode the same bytes (0xe2 0x98 0xba 0x0a):
+	//	* "☺\n" - the literal UTF-8 character and an escaped newline
+	//	* "\u{263a}\u{0a}" - Unicode code points as hexadecimal
+	//	* "\e2\98\ba\0a" - each byte as a two digit hexadecimal escape
+	//
+	// Note: Unlike elsewhere in the source, non-ASCII characters are allowed inside a string.
+	//
+	// See https://www.w3.org/TR/wasm-core-1/#strings%E2%91%A0
+	TokenString
+
+	// TokenID is a sequence of idchar characters prefixed by '$' which symbolically names a module field or local,
+	// ex. "$main" or "$foo.bar".
+	//
+	// See https://www.w3.org/TR/wasm-core-1/#indices%E2%91%A4
+	TokenID
+
+	// TokenLParen is a left parenthesis '(', which begins an s-expression.
+	TokenLParen
+
+	// TokenRParen is a right parenthesis ')', which ends an s-expression.
+	TokenRParen
+
+	// TokenReserved is a sequence of idchar characters which is neither a TokenKeyword, a number, nor a TokenID, ex.
+	// "0$y" or "$". The lexer emits these instead of failing, so that the parser can report a more relevant error.
+	//
+	// See https://www.w3.org/TR/wasm-core-1/#text-reserved
+	TokenReserved
+
+	// TokenEOF is emitted once after all other tokens, when the source lexed without error. Its begin and end
+	// positions are both the length of the source.
+	TokenEOF
+)
+
+var tokenNames = [...]string{
+	TokenKeyword:  "keyword",
+	TokenUN:       "uN",
+	TokenSN:       "sN",
+	TokenFN:       "fN",
+	TokenString:   "string",
+	TokenID:       "id",
+	TokenLParen:   "(",
+	TokenRParen:   ")",
+	TokenReserved: "reserved",
+	TokenEOF:      "EOF",
+}
+
+// String returns the string name of this token.
+func (t TokenType) String() string {
+	if int(t) < len(tokenNames) {
+		return tokenNames[t]
+	}
+	return fmt.Sprintf("token(%d)", t)
+}
@@ -0,0 +1,42 @@
+package text
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// token is a lexed token, used to compare the results of lex in tests.
+type token struct {
+	tokenType TokenType
+	line, col int
+	pos       int
+	token     string
+}
+
+// String helps format tokens when tests fail.
+func (t *token) String() string {
+	return fmt.Sprintf("{%s, %d, %d, %d, %q}", t.tokenType, t.line, t.col, t.pos, t.token)
+}
+
+func TestTokenType_String(t *testing.T) {
+	for _, c := range []struct {
+		tokenType TokenType
+		expected  string
+	}{
+		{TokenKeyword, "keyword"},
+		{TokenUN, "uN"},
+		{TokenSN, "sN"},
+		{TokenFN, "fN"},
+		{TokenString, "string"},
+		{TokenID, "id"},
+		{TokenLParen, "("},
+		{TokenRParen, ")"},
+		{TokenReserved, "reserved"},
+		{TokenEOF, "EOF"},
+		{TokenType(255), "token(255)"},
+	} {
+		require.Equal(t, c.expected, c.tokenType.String())
+	}
+}
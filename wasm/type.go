@@ -1,8 +1,10 @@
 package wasm
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/tetratelabs/wazero/wasm/leb128"
 )
@@ -11,6 +13,12 @@ type FunctionType struct {
 	InputTypes, ReturnTypes []ValueType
 }
 
+// String returns the signature of the function type, ex. "[i32 i32]->[i64]".
+func (t *FunctionType) String() string {
+	return fmt.Sprintf("[%s]->[%s]",
+		strings.Join(valueTypeStrings(t.InputTypes), " "), strings.Join(valueTypeStrings(t.ReturnTypes), " "))
+}
+
 func readFunctionType(r io.Reader) (*FunctionType, error) {
 	b := make([]byte, 1)
 	if _, err := io.ReadFull(r, b); err != nil {
@@ -159,3 +167,204 @@ func readGlobalType(r io.Reader) (*GlobalType, error) {
 	}
 	return ret, nil
 }
+
+// RemoveUnusedTypes returns a copy of the module without function types that nothing references, and with the
+// remaining type indices renumbered.
+//
+// A type is used when a function, a function import, a call_indirect or a block refers to it. The call_indirect and
+// block references are found by decoding the code section, which is an error if a body is malformed.
+func (m *Module) RemoveUnusedTypes() (*Module, error) {
+	used := make([]bool, len(m.TypeSection))
+	markUsed := func(index uint32) error {
+		if int(index) >= len(used) {
+			return fmt.Errorf("unknown type index %d", index)
+		}
+		used[index] = true
+		return nil
+	}
+	for i, index := range m.FunctionSection {
+		if err := markUsed(index); err != nil {
+			return nil, fmt.Errorf("function %d: %w", i, err)
+		}
+	}
+	for i, is := range m.ImportSection {
+		if is.Desc.Kind == ImportKindFunction {
+			if err := markUsed(*is.Desc.TypeIndexPtr); err != nil {
+				return nil, fmt.Errorf("import %d: %w", i, err)
+			}
+		}
+	}
+	immediates := make([][]typeIndexImmediate, len(m.CodeSection))
+	for i, c := range m.CodeSection {
+		var err error
+		if immediates[i], err = readTypeIndexImmediates(c.Body); err != nil {
+			return nil, fmt.Errorf("code %d: %w", i, err)
+		}
+		for _, imm := range immediates[i] {
+			if err := markUsed(imm.index); err != nil {
+				return nil, fmt.Errorf("code %d: %w", i, err)
+			}
+		}
+	}
+
+	newIndices := make([]uint32, len(m.TypeSection))
+	ret := *m
+	ret.TypeSection = nil
+	for i, t := range m.TypeSection {
+		if used[i] {
+			newIndices[i] = uint32(len(ret.TypeSection))
+			ret.TypeSection = append(ret.TypeSection, t)
+		}
+	}
+	if len(ret.TypeSection) == len(m.TypeSection) {
+		ret.TypeSection = m.TypeSection
+		return &ret, nil
+	}
+
+	ret.FunctionSection = make([]uint32, len(m.FunctionSection))
+	for i, index := range m.FunctionSection {
+		ret.FunctionSection[i] = newIndices[index]
+	}
+	ret.ImportSection = make([]*ImportSegment, len(m.ImportSection))
+	for i, is := range m.ImportSection {
+		if is.Desc.Kind == ImportKindFunction {
+			index := newIndices[*is.Desc.TypeIndexPtr]
+			desc := *is.Desc
+			desc.TypeIndexPtr = &index
+			is = &ImportSegment{Module: is.Module, Name: is.Name, Desc: &desc}
+		}
+		ret.ImportSection[i] = is
+	}
+	ret.CodeSection = make([]*CodeSegment, len(m.CodeSection))
+	for i, c := range m.CodeSection {
+		ret.CodeSection[i] = c
+		if len(immediates[i]) > 0 {
+			ret.CodeSection[i] = &CodeSegment{
+				NumLocals:  c.NumLocals,
+				LocalTypes: c.LocalTypes,
+				Body:       renumberTypeIndexImmediates(c.Body, immediates[i], newIndices),
+			}
+		}
+	}
+	return &ret, nil
+}
+
+// typeIndexImmediate is a type index in a function body: either the type of a call_indirect, or a block type which
+// isn't empty or a single value type.
+type typeIndexImmediate struct {
+	// begin and end are the positions of the encoded index in the body.
+	begin, end int
+	index      uint32
+	blockType  bool
+}
+
+// readTypeIndexImmediates returns the type indices in the function body, in order.
+func readTypeIndexImmediates(body []byte) (ret []typeIndexImmediate, err error) {
+	err = walkInstructions(body, func(pc, next int) error {
+		switch body[pc] {
+		case OptCodeBlock, OptCodeLoop, OptCodeIf:
+			// The immediate was already decoded by walkInstructions, so this can't fail.
+			raw, _, _ := leb128.DecodeInt33AsInt64(bytes.NewReader(body[pc+1 : next]))
+			if raw >= 0 {
+				ret = append(ret, typeIndexImmediate{begin: pc + 1, end: next, index: uint32(raw), blockType: true})
+			}
+		case OptCodeCallIndirect:
+			index, num, _ := leb128.DecodeUint32(bytes.NewReader(body[pc+1 : next]))
+			ret = append(ret, typeIndexImmediate{begin: pc + 1, end: pc + 1 + int(num), index: index})
+		}
+		return nil
+	})
+	return
+}
+
+// walkInstructions calls visit with the position of each instruction in the function body, in order, and the position
+// of the next instruction, which is after any immediates. This returns an error if an immediate is malformed or
+// truncated, or visit returns one.
+func walkInstructions(body []byte, visit func(pc, next int) error) error {
+	// skipUint32 skips the unsigned immediate at pos.
+	skipUint32 := func(pos int) (int, error) {
+		_, num, err := leb128.DecodeUint32(bytes.NewReader(body[pos:]))
+		return pos + int(num), err
+	}
+	var err error
+	for pc := 0; pc < len(body); {
+		next := pc + 1
+		switch op := body[pc]; {
+		case op == OptCodeBlock || op == OptCodeLoop || op == OptCodeIf:
+			_, num, err := leb128.DecodeInt33AsInt64(bytes.NewReader(body[next:]))
+			if err != nil {
+				return fmt.Errorf("read block type at %d: %w", pc, err)
+			}
+			next += int(num)
+		case op == OptCodeCallIndirect:
+			if next, err = skipUint32(next); err != nil {
+				return fmt.Errorf("read call_indirect type index at %d: %w", pc, err)
+			}
+			next++ // the table index is a zero byte
+		case op == OptCodeBr || op == OptCodeBrIf || op == OptCodeCall || (OptCodeLocalGet <= op && op <= OptCodeGlobalSet):
+			if next, err = skipUint32(next); err != nil {
+				return fmt.Errorf("read immediate at %d: %w", pc, err)
+			}
+		case op == OptCodeBrTable:
+			count, num, err := leb128.DecodeUint32(bytes.NewReader(body[next:]))
+			if err != nil {
+				return fmt.Errorf("read br_table count at %d: %w", pc, err)
+			}
+			next += int(num)
+			for i := uint64(0); i <= uint64(count); i++ { // the targets, then the default target
+				if next, err = skipUint32(next); err != nil {
+					return fmt.Errorf("read br_table target at %d: %w", pc, err)
+				}
+			}
+		case OptCodeI32Load <= op && op <= OptCodeI64Store32:
+			for i := 0; i < 2; i++ { // align and offset
+				if next, err = skipUint32(next); err != nil {
+					return fmt.Errorf("read memory immediate at %d: %w", pc, err)
+				}
+			}
+		case op == OptCodeMemorySize || op == OptCodeMemoryGrow:
+			next++ // the memory index is a zero byte
+		case op == OptCodeI32Const:
+			_, num, err := leb128.DecodeInt32(bytes.NewReader(body[next:]))
+			if err != nil {
+				return fmt.Errorf("read i32.const at %d: %w", pc, err)
+			}
+			next += int(num)
+		case op == OptCodeI64Const:
+			_, num, err := leb128.DecodeInt64(bytes.NewReader(body[next:]))
+			if err != nil {
+				return fmt.Errorf("read i64.const at %d: %w", pc, err)
+			}
+			next += int(num)
+		case op == OptCodeF32Const:
+			next += 4
+		case op == OptCodeF64Const:
+			next += 8
+		}
+		if next > len(body) {
+			return fmt.Errorf("read immediate at %d: %w", pc, io.ErrUnexpectedEOF)
+		}
+		if err = visit(pc, next); err != nil {
+			return err
+		}
+		pc = next
+	}
+	return nil
+}
+
+// renumberTypeIndexImmediates returns a copy of the function body with each type index replaced by its new index.
+func renumberTypeIndexImmediates(body []byte, immediates []typeIndexImmediate, newIndices []uint32) []byte {
+	ret := make([]byte, 0, len(body))
+	prev := 0
+	for _, imm := range immediates {
+		ret = append(ret, body[prev:imm.begin]...)
+		index := newIndices[imm.index]
+		if imm.blockType {
+			ret = append(ret, leb128.EncodeInt64(int64(index))...)
+		} else {
+			ret = append(ret, leb128.EncodeUint32(index)...)
+		}
+		prev = imm.end
+	}
+	return append(ret, body[prev:]...)
+}
@@ -0,0 +1,109 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFunctionType_String(t *testing.T) {
+	for _, c := range []struct {
+		functionType *FunctionType
+		expected     string
+	}{
+		{functionType: &FunctionType{}, expected: "[]->[]"},
+		{functionType: &FunctionType{InputTypes: []ValueType{ValueTypeI32}}, expected: "[i32]->[]"},
+		{
+			functionType: &FunctionType{InputTypes: []ValueType{ValueTypeI32, ValueTypeF64}, ReturnTypes: []ValueType{ValueTypeI64}},
+			expected:     "[i32 f64]->[i64]",
+		},
+	} {
+		require.Equal(t, c.expected, c.functionType.String())
+	}
+}
+
+func TestModule_RemoveUnusedTypes(t *testing.T) {
+	noParams := &FunctionType{}
+	i32Param := &FunctionType{InputTypes: []ValueType{ValueTypeI32}}
+	i32Result := &FunctionType{ReturnTypes: []ValueType{ValueTypeI32}}
+	i64Param := &FunctionType{InputTypes: []ValueType{ValueTypeI64}}
+	typeIndex := func(index uint32) *uint32 { return &index }
+
+	t.Run("removes one unused type", func(t *testing.T) {
+		m := &Module{
+			TypeSection:     []*FunctionType{noParams, i32Param, i32Result},
+			ImportSection:   []*ImportSegment{{Module: "env", Name: "log", Desc: &ImportDesc{Kind: ImportKindFunction, TypeIndexPtr: typeIndex(2)}}},
+			FunctionSection: []uint32{0},
+			CodeSection:     []*CodeSegment{{Body: []byte{OptCodeEnd}}},
+		}
+		actual, err := m.RemoveUnusedTypes()
+		require.NoError(t, err)
+		require.Equal(t, []*FunctionType{noParams, i32Result}, actual.TypeSection)
+		require.Equal(t, []uint32{0}, actual.FunctionSection)
+		require.Equal(t, uint32(1), *actual.ImportSection[0].Desc.TypeIndexPtr)
+
+		// The original module must not be modified.
+		require.Equal(t, 3, len(m.TypeSection))
+		require.Equal(t, uint32(2), *m.ImportSection[0].Desc.TypeIndexPtr)
+	})
+
+	t.Run("keeps types only used by call_indirect and blocks", func(t *testing.T) {
+		m := &Module{
+			TypeSection:     []*FunctionType{i64Param, noParams, i32Param, i32Result},
+			FunctionSection: []uint32{1},
+			CodeSection: []*CodeSegment{{NumLocals: 1, LocalTypes: []ValueType{ValueTypeI32}, Body: []byte{
+				OptCodeI64Const, 0x80, 0x01, // a multi-byte immediate that isn't a type index
+				OptCodeDrop,
+				OptCodeI32Const, 0x00,
+				OptCodeI32Const, 0x00,
+				OptCodeCallIndirect, 0x02, 0x00, // (call_indirect (type 2))
+				OptCodeBlock, 0x03, // (block (type 3)
+				OptCodeI32Const, 0x01,
+				OptCodeEnd, // )
+				OptCodeDrop,
+				OptCodeBlock, 0x7f, // (block (result i32)
+				OptCodeI32Const, 0x01,
+				OptCodeEnd, // )
+				OptCodeLocalSet, 0x00,
+				OptCodeEnd,
+			}}},
+		}
+		actual, err := m.RemoveUnusedTypes()
+		require.NoError(t, err)
+		require.Equal(t, []*FunctionType{noParams, i32Param, i32Result}, actual.TypeSection)
+		require.Equal(t, []uint32{0}, actual.FunctionSection)
+		require.Equal(t, &CodeSegment{NumLocals: 1, LocalTypes: []ValueType{ValueTypeI32}, Body: []byte{
+			OptCodeI64Const, 0x80, 0x01,
+			OptCodeDrop,
+			OptCodeI32Const, 0x00,
+			OptCodeI32Const, 0x00,
+			OptCodeCallIndirect, 0x01, 0x00, // (call_indirect (type 1))
+			OptCodeBlock, 0x02, // (block (type 2)
+			OptCodeI32Const, 0x01,
+			OptCodeEnd,
+			OptCodeDrop,
+			OptCodeBlock, 0x7f,
+			OptCodeI32Const, 0x01,
+			OptCodeEnd,
+			OptCodeLocalSet, 0x00,
+			OptCodeEnd,
+		}}, actual.CodeSection[0])
+	})
+
+	t.Run("nothing to remove", func(t *testing.T) {
+		m := &Module{TypeSection: []*FunctionType{noParams}, FunctionSection: []uint32{0}, CodeSection: []*CodeSegment{{Body: []byte{OptCodeEnd}}}}
+		actual, err := m.RemoveUnusedTypes()
+		require.NoError(t, err)
+		require.Equal(t, m, actual)
+	})
+
+	t.Run("unknown type index", func(t *testing.T) {
+		m := &Module{
+			TypeSection:     []*FunctionType{noParams},
+			FunctionSection: []uint32{0},
+			CodeSection:     []*CodeSegment{{Body: []byte{OptCodeI32Const, 0x00, OptCodeCallIndirect, 0x01, 0x00, OptCodeEnd}}},
+		}
+		_, err := m.RemoveUnusedTypes()
+		require.EqualError(t, err, "code 0: unknown type index 1")
+	})
+}
@@ -17,6 +17,29 @@ const (
 	ValueTypeF64 ValueType = 0x7c
 )
 
+// valueTypeName returns the text format name of the value type, ex. "i32", or "unknown" if it isn't one.
+func valueTypeName(t ValueType) string {
+	switch t {
+	case ValueTypeI32:
+		return "i32"
+	case ValueTypeI64:
+		return "i64"
+	case ValueTypeF32:
+		return "f32"
+	case ValueTypeF64:
+		return "f64"
+	}
+	return "unknown"
+}
+
+func valueTypeStrings(types []ValueType) []string {
+	ret := make([]string, len(types))
+	for i, v := range types {
+		ret[i] = valueTypeName(v)
+	}
+	return ret
+}
+
 func readValueTypes(r io.Reader, num uint32) ([]ValueType, error) {
 	ret := make([]ValueType, num)
 	buf := make([]ValueType, num)
@@ -1,10 +1,12 @@
 package wazeroir
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/tetratelabs/wazero/wasm"
 	"github.com/tetratelabs/wazero/wasm/buildoptions"
 )
 
@@ -38,3 +40,77 @@ func TestInterpreter_PushFrame_StackOverflow(t *testing.T) {
 	it.pushFrame(f3)
 	require.Panics(t, func() { it.pushFrame(f4) })
 }
+
+func TestInterpreter_MemoryStore(t *testing.T) {
+	// (module (memory 1) (func (export "main") (i32.store (i32.const 0) (i32.const 55))))
+	buf := []byte{
+		0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, // magic + version
+		0x01, 0x04, 0x01, 0x60, 0x00, 0x00, // type section: [] -> []
+		0x03, 0x02, 0x01, 0x00, // function section
+		0x05, 0x03, 0x01, 0x00, 0x01, // memory section: min=1
+		0x07, 0x08, 0x01, 0x04, 'm', 'a', 'i', 'n', 0x00, 0x00, // export section
+		0x0a, 0x0b, 0x01, 0x09, 0x00, // code section
+		0x41, 0x00, // i32.const 0
+		0x41, 0x37, // i32.const 55
+		0x36, 0x02, 0x00, // i32.store align=2 offset=0
+		0x0b, // end
+	}
+	mod, err := wasm.DecodeModule(buf)
+	require.NoError(t, err)
+	store := wasm.NewStore(NewEngine())
+	require.NoError(t, store.Instantiate(mod, "test"))
+
+	_, _, err = store.CallFunction("test", "main")
+	require.NoError(t, err)
+
+	v, ok := store.ModuleInstances["test"].Memory.ReadUint32(0)
+	require.True(t, ok)
+	require.Equal(t, uint32(55), v)
+}
+
+func TestInterpreter_HostFunctionImport(t *testing.T) {
+	// (module
+	//   (import "env" "add" (func $add (param i32 i32) (result i32)))
+	//   (func (export "call_add") (param i32 i32) (result i32)
+	//     (call $add (local.get 0) (local.get 1))))
+	buf := []byte{
+		0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, // magic + version
+		0x01, 0x07, 0x01, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7f, // type section: [i32, i32] -> [i32]
+		0x02, 0x0b, 0x01, 0x03, 'e', 'n', 'v', 0x03, 'a', 'd', 'd', 0x00, 0x00, // import section
+		0x03, 0x02, 0x01, 0x00, // function section
+		0x07, 0x0c, 0x01, 0x08, 'c', 'a', 'l', 'l', '_', 'a', 'd', 'd', 0x00, 0x01, // export section
+		0x0a, 0x0a, 0x01, 0x08, 0x00, // code section
+		0x20, 0x00, // local.get 0
+		0x20, 0x01, // local.get 1
+		0x10, 0x00, // call 0
+		0x0b, // end
+	}
+	mod, err := wasm.DecodeModule(buf)
+	require.NoError(t, err)
+	store := wasm.NewStore(NewEngine())
+
+	var called bool
+	add := func(_ *wasm.HostFunctionCallContext, x, y uint32) uint32 {
+		called = true
+		return x + y
+	}
+	require.NoError(t, store.AddHostFunction("env", "add", reflect.ValueOf(add)))
+	require.NoError(t, store.Instantiate(mod, "test"))
+
+	out, _, err := store.CallFunction("test", "call_add", 1, 2)
+	require.NoError(t, err)
+	require.True(t, called)
+	require.Equal(t, []uint64{3}, out)
+}
+
+func TestInterpreter_EmptyModule(t *testing.T) {
+	mod, err := wasm.DecodeModule([]byte("\x00asm\x01\x00\x00\x00"))
+	require.NoError(t, err)
+	store := wasm.NewStore(NewEngine())
+	require.NoError(t, store.Instantiate(mod, "empty"))
+
+	inst := store.ModuleInstances["empty"]
+	require.Empty(t, inst.Functions)
+	require.Empty(t, inst.Exports)
+	require.Nil(t, inst.Memory)
+}